@@ -2,53 +2,160 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 
+	"github.com/zendesk/melbourne_code_club_go/internal/cli"
+	"github.com/zendesk/melbourne_code_club_go/internal/config"
+	"github.com/zendesk/melbourne_code_club_go/internal/expr"
 	indexpkg "github.com/zendesk/melbourne_code_club_go/internal/index"
-	"github.com/zendesk/melbourne_code_club_go/internal/search"
+	"github.com/zendesk/melbourne_code_club_go/internal/output"
 	"github.com/zendesk/melbourne_code_club_go/internal/types"
 	"github.com/zendesk/melbourne_code_club_go/internal/ui"
+	"github.com/zendesk/melbourne_code_club_go/internal/util"
 )
 
 func main() {
-	ctx := context.Background()
-	indexChannel := make(chan types.Index)
-	var index types.Index
-	var syncOnce sync.Once
-	enableGracefulShutdown()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// Do this in the background
+	cfg, args, err := config.Load(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(cli.ExitFound)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(cli.ExitUsage)
+	}
+
+	enableGracefulShutdown(cancel)
+
+	if len(args) > 0 {
+		os.Exit(cli.Run(ctx, cfg, args, os.Stdout, os.Stderr))
+	}
+
+	progress := &ui.Progress{}
+	live := indexpkg.StartLive(ctx, cfg, progress.Report)
+	loading := live.Loading()
+	stopped := make(chan struct{})
+
+	// Loading carries on in the background while the user picks a query
+	go func() {
+		defer close(stopped)
+
+		<-loading.Done()
+		progress.Finish()
+		if err := loading.SnapshotErr(); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to save the index snapshot:", err)
+		}
+		warnLoadFailed(loading)
+
+		if loading.Err() == nil && cfg.ReloadInterval > 0 {
+			live.Watch(ctx, cfg.ReloadInterval, os.Stderr)
+		}
+	}()
+
+	// A prompt waiting for input can't be cancelled, so once loading and
+	// reloading have stopped the program exits from here
 	go func() {
-		indexChannel <- indexpkg.LoadAndIndexData(ctx)
+		<-ctx.Done()
+		exitInterrupted(stopped)
 	}()
 
 	// Loop these two
 	for {
-		query, err := ui.PromptUser()
+		expression, err := ui.PromptUser(progress)
 
 		if err != nil {
 			fmt.Println("Goodbye")
 			return
 		}
 
-		syncOnce.Do(
-			func() {
-				index = <-indexChannel
-			})
-		fmt.Println(search.SearchData(index, query))
+		// Only wait for the datasets this search and its related records
+		// come from
+		needed := types.RelatedDatasets(expr.DatasetOf(expression), output.DefaultDepth)
+		if waiting := stillLoading(loading, needed); len(waiting) > 0 {
+			fmt.Fprintf(os.Stderr, "Waiting for %s to load\n", strings.Join(waiting, ", "))
+			progress.Show(os.Stderr, loading.Ready(needed...))
+		}
+		if err := loading.Failed(needed...); err != nil {
+			exitLoadFailed(err, stopped)
+		}
+
+		// The whole search runs against one snapshot, even if a reload
+		// swaps in new data meanwhile
+		store := live.Store()
+
+		found, err := expression.Eval(store)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Search failed:", err)
+			continue
+		}
+
+		if err := (output.Text{Depth: output.DefaultDepth}).Render(os.Stdout, store, found); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to print results:", err)
+		}
+	}
+}
+
+// warnLoadFailed names the datasets that failed to load once loading is
+// done. Searches that don't need them still run.
+func warnLoadFailed(loading *indexpkg.Loader) {
+	for _, dataset := range types.Datasets {
+		if err := loading.Failed(dataset); err != nil && !errors.Is(err, context.Canceled) {
+			fmt.Fprintf(os.Stderr, "Failed to load %s, searches needing it won't run: %v\n", dataset, err)
+		}
+	}
+}
+
+func exitLoadFailed(err error, stopped <-chan struct{}) {
+	if errors.Is(err, context.Canceled) {
+		exitInterrupted(stopped)
 	}
+	fmt.Fprintln(os.Stderr, "Failed to load data:", err)
+	os.Exit(cli.ExitFailure)
+}
+
+// stillLoading returns those of the datasets the loader hasn't finished
+// with yet.
+func stillLoading(loading *indexpkg.Loader, datasets []string) []string {
+	var waiting []string
+	for _, dataset := range loading.Pending() {
+		if util.ContainsString(datasets, dataset) {
+			waiting = append(waiting, dataset)
+		}
+	}
+	return waiting
+}
+
+var interrupted sync.Once
+
+// exitInterrupted exits once the background work has stopped. Whoever gets
+// here first says goodbye; anyone else waits for the exit.
+func exitInterrupted(stopped <-chan struct{}) {
+	<-stopped
+	interrupted.Do(func() {
+		fmt.Println("Goodbye")
+		os.Exit(cli.ExitInterrupted)
+	})
 }
 
-func enableGracefulShutdown() {
+// enableGracefulShutdown cancels the context on SIGINT or SIGTERM, which
+// stops loading, indexing and serving. A second signal kills the program
+// straight away.
+func enableGracefulShutdown(cancel context.CancelFunc) {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		sig := <-sigs
-		fmt.Println("got a signal - ", sig)
+		signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+		fmt.Fprintf(os.Stderr, "\nGot %v, shutting down\n", sig)
+		cancel()
 	}()
-	fmt.Println("awaiting signal")
 }
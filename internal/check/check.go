@@ -0,0 +1,110 @@
+// Package check looks for records that break the relationships between the
+// datasets.
+package check
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/index"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// Problem is an integrity problem found in a record.
+type Problem struct {
+	Dataset string
+	// Id is the _id of the record with the problem.
+	Id      interface{}
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s %v: %s", p.Dataset, p.Id, p.Message)
+}
+
+// Run checks every dataset of the store against its schema: values of
+// Unique fields must not repeat within the dataset and References fields
+// must hold the _id of an existing record.
+func Run(store *index.Store) []Problem {
+	var problems []Problem
+
+	for _, dataset := range types.Datasets {
+		schema := types.Schemas[dataset]
+		records := store.Records(dataset)
+
+		for _, field := range schema.Fields {
+			if field.Unique {
+				problems = append(problems, duplicates(schema, field, records)...)
+			}
+			if field.References != "" {
+				problems = append(problems, danglingReferences(store, schema, field, records)...)
+			}
+		}
+	}
+
+	return problems
+}
+
+// duplicates reports each repeated value once, against the first record
+// holding it.
+func duplicates(schema *types.Schema, field types.Field, records []types.Record) []Problem {
+	idField, _ := schema.Field("_id")
+
+	var order []interface{}
+	holders := map[interface{}][]int{}
+	for i, record := range records {
+		value := schema.Value(record, field)
+		if value == nil {
+			continue
+		}
+		if _, ok := holders[value]; !ok {
+			order = append(order, value)
+		}
+		holders[value] = append(holders[value], i)
+	}
+
+	var problems []Problem
+	for _, value := range order {
+		positions := holders[value]
+		if len(positions) < 2 {
+			continue
+		}
+
+		first := records[positions[0]]
+		message := fmt.Sprintf("%s %v is repeated in %d records", field.Name, value, len(positions))
+		if field.Name != idField.Name {
+			others := make([]string, len(positions)-1)
+			for i, position := range positions[1:] {
+				others[i] = fmt.Sprint(schema.Value(records[position], idField))
+			}
+			message = fmt.Sprintf("%s %q is also used by %s %s", field.Name, value, schema.Dataset, strings.Join(others, ", "))
+		}
+
+		problems = append(problems, Problem{Dataset: schema.Dataset, Id: schema.Value(first, idField), Message: message})
+	}
+
+	return problems
+}
+
+func danglingReferences(store *index.Store, schema *types.Schema, field types.Field, records []types.Record) []Problem {
+	idField, _ := schema.Field("_id")
+
+	var problems []Problem
+	for _, record := range records {
+		value := schema.Value(record, field)
+		if value == nil {
+			continue
+		}
+
+		target := types.Query{Dataset: field.References, Field: "_id", Value: value}
+		if len(store.Lookup(target)) == 0 {
+			problems = append(problems, Problem{
+				Dataset: schema.Dataset,
+				Id:      schema.Value(record, idField),
+				Message: fmt.Sprintf("%s %v matches no %s _id", field.Name, value, field.References),
+			})
+		}
+	}
+
+	return problems
+}
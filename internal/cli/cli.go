@@ -0,0 +1,398 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/check"
+	"github.com/zendesk/melbourne_code_club_go/internal/config"
+	"github.com/zendesk/melbourne_code_club_go/internal/expr"
+	indexpkg "github.com/zendesk/melbourne_code_club_go/internal/index"
+	"github.com/zendesk/melbourne_code_club_go/internal/output"
+	"github.com/zendesk/melbourne_code_club_go/internal/search"
+	"github.com/zendesk/melbourne_code_club_go/internal/server"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+	"github.com/zendesk/melbourne_code_club_go/internal/util"
+)
+
+// Exit codes returned by Run.
+const (
+	ExitFound     = 0
+	ExitNoResults = 1
+	ExitUsage     = 2
+	ExitFailure   = 3
+	// ExitProblems is returned by check when the data has integrity problems.
+	ExitProblems = 4
+	// ExitInterrupted is returned when SIGINT or SIGTERM stopped the
+	// command, the status shells give a process killed by SIGINT.
+	ExitInterrupted = 130
+)
+
+// DefaultAddr is where serve listens unless told otherwise.
+const DefaultAddr = "localhost:8080"
+
+const usage = `Usage:
+  melbourne_code_club_go [flags]                         start the interactive prompt
+  melbourne_code_club_go [flags] search [-match <mode>] [output flags] <dataset> <field> <value>
+  melbourne_code_club_go [flags] query [output flags] <dataset> <expression>
+  melbourne_code_club_go [flags] list_fields [dataset]
+  melbourne_code_club_go [flags] serve [-addr <host:port>]
+  melbourne_code_club_go [flags] create <dataset> <field>=<value>...
+  melbourne_code_club_go [flags] update <dataset> <id> <field>=<value>...
+  melbourne_code_club_go [flags] delete <dataset> <id>
+  melbourne_code_club_go [flags] check
+
+Flags:
+  -config <file>          YAML config file (env MCC_CONFIG)
+  -data-dir <dir>         directory holding <dataset>.json files (env MCC_DATA_DIR)
+  -users <file>           users JSON file (env MCC_USERS_FILE)
+  -organizations <file>   organizations JSON file (env MCC_ORGANIZATIONS_FILE)
+  -tickets <file>         tickets JSON file (env MCC_TICKETS_FILE)
+  -reload <duration>      how often the prompt and serve reload changed files, 0 for never
+                          (default 2s, env MCC_RELOAD_INTERVAL)
+  -workers <n>            how many goroutines build the index, 0 for one per CPU
+                          (default 0, env MCC_INDEX_WORKERS)
+  -snapshot <file>        file the index is saved to for a fast start, none for no snapshot
+                          (default none, env MCC_SNAPSHOT)
+
+serve answers GET /search?dataset=&field=&value=[&match=], GET /fields/<dataset> and
+GET /<dataset>/<id> with JSON, listening on -addr (default ` + DefaultAddr + `).
+
+create, update and delete change a dataset and save its file, keeping the layout of the records.
+Values are read like search values, lists such as tags=a,b,c are comma separated and @missing
+removes a field.
+
+check reports references to missing users and organizations and repeated _id and external_id
+values, exiting with 4 when it finds any.
+
+Datasets: users, organizations, tickets
+Match modes: exact (default), iexact, prefix, contains, regex, text, gt, gte, lt, lte, between.
+iexact, prefix, contains and regex only match text values; iexact, prefix and contains ignore
+case, use (?i) for a regex.
+text is a ranked full text search of tickets subject/description and organizations details:
+words must all match, OR separates alternatives ("korea north OR micronesia").
+gt, gte, lt, lte and between compare ids and timestamps, between takes an inclusive <from>..<to>
+value. Timestamps are written like the data (2016-04-15T05:19:46 -10:00), in RFC 3339 or as
+a date (2016-04-15), read as UTC when no zone is given.
+
+Output flags:
+  -format <format>        text (default), json, ndjson, csv, tsv, table or markdown
+  -columns <fields>       comma separated fields written by csv, tsv, table and markdown
+                          (default all)
+  -depth <n>              how many relationships away related records are written by text, json
+                          and ndjson (default 1, 0 for none)
+json writes an array of records and ndjson a record per line. JSON records hold their fields, the
+records they refer to, such as "submitter", and lists of the records referring to them, such as
+"submitted_tickets".
+
+Query expressions are ` + expr.Syntax + `
+
+Values are read as the field's type: whole numbers for ids, true or false, timestamps, or text
+with optional double quotes. For list fields like tags give a single element. @missing matches
+records without the field and @empty (or "") those where it is blank or an empty list.
+`
+
+// Run executes a single non-interactive command and returns the process exit code.
+func Run(ctx context.Context, cfg config.Config, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "search":
+		return runSearch(ctx, cfg, args[1:], stdout, stderr)
+	case "query":
+		return runQuery(ctx, cfg, args[1:], stdout, stderr)
+	case "list_fields":
+		return runListFields(args[1:], stdout, stderr)
+	case "serve":
+		return runServe(ctx, cfg, args[1:], stderr)
+	case "create":
+		return runCreate(ctx, cfg, args[1:], stdout, stderr)
+	case "update":
+		return runUpdate(ctx, cfg, args[1:], stdout, stderr)
+	case "delete":
+		return runDelete(ctx, cfg, args[1:], stdout, stderr)
+	case "check":
+		return runCheck(ctx, cfg, args[1:], stdout, stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return ExitFound
+	default:
+		fmt.Fprintf(stderr, "Unknown command %q\n\n%s", args[0], usage)
+		return ExitUsage
+	}
+}
+
+func runSearch(ctx context.Context, cfg config.Config, args []string, stdout io.Writer, stderr io.Writer) int {
+	var matchMode string
+	var outputs outputFlags
+
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&matchMode, "match", string(search.Exact), "how the value is matched")
+	outputs.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+	args = flags.Args()
+
+	if len(args) != 3 {
+		fmt.Fprintf(stderr, "search expects 3 arguments, got %d\n\n%s", len(args), usage)
+		return ExitUsage
+	}
+
+	dataset, field, rawValue := args[0], args[1], args[2]
+
+	if err := validateField(dataset, field); err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	mode, err := search.ParseMode(matchMode)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	renderer, err := outputs.renderer(dataset)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	criteria, err := search.NewCriteria(dataset, field, mode, rawValue)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	return runExpression(ctx, cfg, expr.Term{Criteria: criteria}, renderer, outputs.depth, stdout, stderr)
+}
+
+func runQuery(ctx context.Context, cfg config.Config, args []string, stdout io.Writer, stderr io.Writer) int {
+	var outputs outputFlags
+
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	outputs.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+	args = flags.Args()
+
+	if len(args) < 2 {
+		fmt.Fprintf(stderr, "query expects a dataset and an expression\n\n%s", usage)
+		return ExitUsage
+	}
+
+	expression, err := expr.Parse(args[0], strings.Join(args[1:], " "))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	renderer, err := outputs.renderer(args[0])
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	return runExpression(ctx, cfg, expression, renderer, outputs.depth, stdout, stderr)
+}
+
+// runExpression runs the search as soon as the datasets it needs are
+// indexed: its own and those the related records written down to depth
+// come from. The others may still be loading, and only a failure to load
+// those it needs fails the search.
+func runExpression(ctx context.Context, cfg config.Config, expression expr.Expr, renderer output.Renderer, depth int, stdout io.Writer, stderr io.Writer) int {
+	loading := indexpkg.StartLoading(ctx, cfg, nil)
+	if err := loading.Failed(types.RelatedDatasets(expr.DatasetOf(expression), depth)...); err != nil {
+		return loadFailed(stderr, err)
+	}
+	store := loading.Store()
+
+	found, err := expression.Eval(store)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	if err := renderer.Render(stdout, store, found); err != nil {
+		fmt.Fprintln(stderr, "Failed to print results:", err)
+		return ExitFailure
+	}
+
+	// With the results out, let the rest load so the snapshot is saved and
+	// the next search starts from it. Without a snapshot there is nothing
+	// to wait for.
+	if cfg.SnapshotPath() != "" {
+		snapshotFailed(stderr, loading)
+	}
+
+	if len(found) == 0 {
+		return ExitNoResults
+	}
+	return ExitFound
+}
+
+// outputFlags are the flags picking the renderer search and query write
+// results with.
+type outputFlags struct {
+	format  string
+	columns string
+	depth   int
+}
+
+func (o *outputFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.format, "format", string(output.TextFormat), "how results are written")
+	flags.StringVar(&o.columns, "columns", "", "comma separated fields written by tabular formats")
+	flags.IntVar(&o.depth, "depth", output.DefaultDepth, "how many relationships away related records are written")
+}
+
+func (o *outputFlags) renderer(dataset string) (output.Renderer, error) {
+	return output.New(dataset, o.format, o.columns, o.depth)
+}
+
+func runListFields(args []string, stdout io.Writer, stderr io.Writer) int {
+	datasets := types.Datasets
+
+	if len(args) > 1 {
+		fmt.Fprintf(stderr, "list_fields expects at most 1 argument, got %d\n\n%s", len(args), usage)
+		return ExitUsage
+	}
+
+	if len(args) == 1 {
+		if _, ok := types.DataTypes[args[0]]; !ok {
+			fmt.Fprintf(stderr, "Unknown dataset %q\n", args[0])
+			return ExitUsage
+		}
+		datasets = args[:1]
+	}
+
+	for i, dataset := range datasets {
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintln(stdout, strings.ToUpper(dataset[:1])+dataset[1:])
+		for _, field := range types.DataTypes[dataset] {
+			fmt.Fprintf(stdout, "- %s\n", field)
+		}
+	}
+
+	return ExitFound
+}
+
+func runServe(ctx context.Context, cfg config.Config, args []string, stderr io.Writer) int {
+	var addr string
+
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&addr, "addr", DefaultAddr, "host:port to listen on")
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "serve expects no arguments, got %d\n\n%s", flags.NArg(), usage)
+		return ExitUsage
+	}
+
+	live, err := indexpkg.LoadLive(ctx, cfg, nil)
+	if err != nil {
+		return loadFailed(stderr, err)
+	}
+	snapshotFailed(stderr, live.Loading())
+
+	if cfg.ReloadInterval > 0 {
+		go live.Watch(ctx, cfg.ReloadInterval, stderr)
+	}
+
+	httpServer := &http.Server{Addr: addr, Handler: server.New(live)}
+
+	go func() {
+		<-ctx.Done()
+		httpServer.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(stderr, "Serving on http://%s\n", addr)
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fmt.Fprintln(stderr, "Server failed:", err)
+		return ExitFailure
+	}
+
+	return ExitFound
+}
+
+func runCheck(ctx context.Context, cfg config.Config, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintf(stderr, "check expects no arguments, got %d\n\n%s", len(args), usage)
+		return ExitUsage
+	}
+
+	store, err := loadData(ctx, cfg, stderr)
+	if err != nil {
+		return loadFailed(stderr, err)
+	}
+
+	problems := check.Run(store)
+	for _, problem := range problems {
+		fmt.Fprintln(stdout, problem)
+	}
+
+	if len(problems) > 0 {
+		fmt.Fprintln(stdout, "Number of problems ", len(problems))
+		return ExitProblems
+	}
+
+	fmt.Fprintln(stdout, "No problems found")
+	return ExitFound
+}
+
+func validateField(dataset string, field string) error {
+	fields, ok := types.DataTypes[dataset]
+	if !ok {
+		return fmt.Errorf("Unknown dataset %q", dataset)
+	}
+
+	if !util.ContainsString(fields, field) {
+		return fmt.Errorf("Unknown field %q for %s", field, dataset)
+	}
+
+	return nil
+}
+
+// loadData loads and indexes every dataset, telling stderr if the snapshot
+// couldn't be saved.
+func loadData(ctx context.Context, cfg config.Config, stderr io.Writer) (*indexpkg.Store, error) {
+	loading := indexpkg.StartLoading(ctx, cfg, nil)
+	if err := loading.Err(); err != nil {
+		return nil, err
+	}
+	snapshotFailed(stderr, loading)
+	return loading.Store(), nil
+}
+
+// snapshotFailed waits for loading to finish and tells stderr if the
+// snapshot couldn't be saved. The data is there either way.
+func snapshotFailed(stderr io.Writer, loading *indexpkg.Loader) {
+	if err := loading.SnapshotErr(); err != nil {
+		fmt.Fprintln(stderr, "Failed to save the index snapshot:", err)
+	}
+}
+
+// loadFailed reports why the data couldn't be loaded and returns the exit
+// code for it.
+func loadFailed(stderr io.Writer, err error) int {
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(stderr, "Interrupted")
+		return ExitInterrupted
+	}
+	fmt.Fprintln(stderr, "Failed to load data:", err)
+	return ExitFailure
+}
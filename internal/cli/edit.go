@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/config"
+	indexpkg "github.com/zendesk/melbourne_code_club_go/internal/index"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// runCreate adds a record built from field=value arguments to its dataset.
+func runCreate(ctx context.Context, cfg config.Config, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) < 2 {
+		fmt.Fprintf(stderr, "create expects a dataset and field=value pairs\n\n%s", usage)
+		return ExitUsage
+	}
+
+	schema, assignments, err := parseAssignments(args[0], args[1:])
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	// Fields given in any order are laid out like the schema
+	var object types.Object
+	for _, field := range schema.Fields {
+		if value, ok := assignments[field.Name]; ok {
+			if err := object.SetField(field, value); err != nil {
+				fmt.Fprintln(stderr, err)
+				return ExitUsage
+			}
+		}
+	}
+
+	record, err := validateObject(schema, object)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	return edit(ctx, cfg, schema, stderr, func(document *types.Document, store *indexpkg.Store) error {
+		if err := store.Insert(record); err != nil {
+			return err
+		}
+		document.Objects = append(document.Objects, object)
+		fmt.Fprintf(stdout, "Created %s %v\n", schema.Dataset, record.PrimaryKey().Value)
+		return nil
+	})
+}
+
+// runUpdate sets the fields given as field=value arguments on a record.
+func runUpdate(ctx context.Context, cfg config.Config, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) < 3 {
+		fmt.Fprintf(stderr, "update expects a dataset, an _id and field=value pairs\n\n%s", usage)
+		return ExitUsage
+	}
+
+	schema, assignments, err := parseAssignments(args[0], args[2:])
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	if _, ok := assignments["_id"]; ok {
+		fmt.Fprintln(stderr, "_id can't be changed")
+		return ExitUsage
+	}
+
+	id, err := parseId(schema, args[1])
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	return edit(ctx, cfg, schema, stderr, func(document *types.Document, store *indexpkg.Store) error {
+		position := document.Find(id)
+		if position < 0 {
+			return fmt.Errorf("%w: %s with _id %v", indexpkg.ErrNotFound, schema.Dataset, id)
+		}
+
+		object := append(types.Object(nil), document.Objects[position]...)
+		for _, field := range schema.Fields {
+			if value, ok := assignments[field.Name]; ok {
+				if err := object.SetField(field, value); err != nil {
+					return &usageError{err}
+				}
+			}
+		}
+
+		record, err := validateObject(schema, object)
+		if err != nil {
+			return err
+		}
+
+		if err := store.Update(record); err != nil {
+			return err
+		}
+		document.Objects[position] = object
+		fmt.Fprintf(stdout, "Updated %s %v\n", schema.Dataset, id)
+		return nil
+	})
+}
+
+// runDelete removes a record from its dataset.
+func runDelete(ctx context.Context, cfg config.Config, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) != 2 {
+		fmt.Fprintf(stderr, "delete expects a dataset and an _id\n\n%s", usage)
+		return ExitUsage
+	}
+
+	schema, ok := types.Schemas[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown dataset %q\n", args[0])
+		return ExitUsage
+	}
+
+	id, err := parseId(schema, args[1])
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	return edit(ctx, cfg, schema, stderr, func(document *types.Document, store *indexpkg.Store) error {
+		position := document.Find(id)
+		if position < 0 {
+			return fmt.Errorf("%w: %s with _id %v", indexpkg.ErrNotFound, schema.Dataset, id)
+		}
+
+		if _, err := store.Delete(schema.Dataset, id); err != nil {
+			return err
+		}
+		document.Objects = append(document.Objects[:position], document.Objects[position+1:]...)
+		fmt.Fprintf(stdout, "Deleted %s %v\n", schema.Dataset, id)
+		return nil
+	})
+}
+
+// usageError marks a change rejected because of what the user asked for.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+// edit loads the data and the dataset's file, applies the change to both
+// and writes the file back. The index is updated first, so a change it
+// rejects leaves the file alone.
+func edit(ctx context.Context, cfg config.Config, schema *types.Schema, stderr io.Writer, change func(*types.Document, *indexpkg.Store) error) int {
+	store, err := loadData(ctx, cfg, stderr)
+	if err != nil {
+		return loadFailed(stderr, err)
+	}
+
+	document, err := types.ReadDocument(cfg.Path(schema.Dataset), schema)
+	if err != nil {
+		fmt.Fprintln(stderr, "Failed to load data:", err)
+		return ExitFailure
+	}
+
+	if err := change(document, store); err != nil {
+		fmt.Fprintln(stderr, err)
+
+		var usageErr *usageError
+		switch {
+		case errors.Is(err, indexpkg.ErrNotFound):
+			return ExitNoResults
+		case errors.Is(err, indexpkg.ErrExists), errors.As(err, &usageErr):
+			return ExitUsage
+		default:
+			return ExitFailure
+		}
+	}
+
+	if err := document.Write(); err != nil {
+		fmt.Fprintln(stderr, "Failed to save data:", err)
+		return ExitFailure
+	}
+
+	return ExitFound
+}
+
+// parseAssignments reads field=value arguments into values by field name.
+func parseAssignments(dataset string, args []string) (*types.Schema, map[string]string, error) {
+	schema, ok := types.Schemas[dataset]
+	if !ok {
+		return nil, nil, fmt.Errorf("Unknown dataset %q", dataset)
+	}
+
+	assignments := map[string]string{}
+	for _, arg := range args {
+		separator := strings.Index(arg, "=")
+		if separator < 0 {
+			return nil, nil, fmt.Errorf("Expected field=value, got %q", arg)
+		}
+
+		name, value := arg[:separator], arg[separator+1:]
+		if _, ok := schema.Field(name); !ok {
+			return nil, nil, fmt.Errorf("Unknown field %q for %s", name, dataset)
+		}
+		if _, ok := assignments[name]; ok {
+			return nil, nil, fmt.Errorf("%s is given more than once", name)
+		}
+		assignments[name] = value
+	}
+
+	return schema, assignments, nil
+}
+
+func parseId(schema *types.Schema, raw string) (interface{}, error) {
+	field, _ := schema.Field("_id")
+	id, err := field.Coerce(raw)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := id.(types.Special); ok {
+		return nil, fmt.Errorf("Expected an _id, got %q", raw)
+	}
+	return id, nil
+}
+
+// validateObject checks the edited object against the schema, like the
+// loaders check the data files, and decodes it.
+func validateObject(schema *types.Schema, object types.Object) (types.Record, error) {
+	raw, err := object.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	if problems := schema.Validate(raw); len(problems) > 0 {
+		return nil, &usageError{fmt.Errorf("Invalid %s record: %s", schema.Dataset, strings.Join(problems, ", "))}
+	}
+
+	return types.DecodeRecord(schema.Dataset, raw)
+}
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/config"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+func TestUpdateWithoutChangeKeepsTheFile(t *testing.T) {
+	cfg := sampleData(t)
+	before := readFile(t, cfg.Path("users"))
+
+	run(t, cfg, ExitFound, "update", "users", "1", "name=Francisca Rasmussen")
+
+	if !bytes.Equal(readFile(t, cfg.Path("users")), before) {
+		t.Error("an update to the same value changed the file")
+	}
+}
+
+func TestUpdateChangesOneMember(t *testing.T) {
+	cfg := sampleData(t)
+	before := readDocument(t, cfg, "users")
+	position := before.Find(1)
+
+	run(t, cfg, ExitFound, "update", "users", "1", "alias=Frankie", "email=@missing")
+
+	after := readDocument(t, cfg, "users")
+	if len(after.Objects) != len(before.Objects) {
+		t.Fatalf("file holds %d users, expected %d", len(after.Objects), len(before.Objects))
+	}
+	for i := range before.Objects {
+		if i != position && !reflect.DeepEqual(after.Objects[i], before.Objects[i]) {
+			t.Errorf("user at %d changed", i)
+		}
+	}
+
+	// Every key but the removed one stays where it was
+	var want types.Object
+	for _, member := range before.Objects[position] {
+		switch member.Key {
+		case "alias":
+			want = append(want, types.Member{Key: "alias", Value: []byte(`"Frankie"`)})
+		case "email":
+		default:
+			want = append(want, member)
+		}
+	}
+	if !reflect.DeepEqual(after.Objects[position], want) {
+		t.Errorf("user 1 is\n%s\nexpected\n%s", marshal(t, after.Objects[position]), marshal(t, want))
+	}
+}
+
+func TestCreateAppendsInSchemaOrder(t *testing.T) {
+	cfg := sampleData(t)
+	before := readDocument(t, cfg, "users")
+
+	run(t, cfg, ExitFound, "create", "users",
+		"role=agent", "name=Ada Quartz", "_id=1000", "tags=a,b", "suspended=false", "url=http://example.com/1000",
+		"external_id=ada-1000", "created_at=2016-04-15", "last_login_at=2016-04-16", "active=true", "shared=false",
+		"phone=8335-422-718", "signature=Don't Worry Be Happy!")
+
+	after := readDocument(t, cfg, "users")
+	if len(after.Objects) != len(before.Objects)+1 {
+		t.Fatalf("file holds %d users, expected %d", len(after.Objects), len(before.Objects)+1)
+	}
+	if !reflect.DeepEqual(after.Objects[:len(before.Objects)], before.Objects) {
+		t.Error("the users already in the file changed")
+	}
+
+	var keys []string
+	for _, member := range after.Objects[len(before.Objects)] {
+		keys = append(keys, member.Key)
+	}
+	want := []string{"_id", "url", "external_id", "name", "created_at", "active", "shared", "last_login_at", "phone", "signature", "tags", "suspended", "role"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("new user has keys %v, expected %v", keys, want)
+	}
+}
+
+func TestRejectedChangesKeepTheFile(t *testing.T) {
+	cfg := sampleData(t)
+	before := readFile(t, cfg.Path("users"))
+
+	for _, c := range []struct {
+		status int
+		args   []string
+	}{
+		{ExitUsage, []string{"create", "users", "_id=1", "name=Ada Quartz", "url=http://example.com/1", "external_id=ada-1",
+			"created_at=2016-04-15", "last_login_at=2016-04-16", "active=true", "shared=false", "phone=8335-422-718",
+			"signature=Hi", "tags=a", "suspended=false", "role=agent"}},
+		{ExitNoResults, []string{"update", "users", "9999", "name=Nobody"}},
+		{ExitNoResults, []string{"delete", "users", "9999"}},
+		{ExitUsage, []string{"update", "users", "1", "verified=maybe"}},
+	} {
+		run(t, cfg, c.status, c.args...)
+
+		if !bytes.Equal(readFile(t, cfg.Path("users")), before) {
+			t.Fatalf("%v changed the file", c.args)
+		}
+	}
+}
+
+// run runs the command line and checks its exit status.
+func run(t *testing.T, cfg config.Config, status int, args ...string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	if got := Run(context.Background(), cfg, args, &stdout, &stderr); got != status {
+		t.Fatalf("%v exited with %d, expected %d: %s", args, got, status, stderr.String())
+	}
+}
+
+// sampleData copies the sample data to a temporary directory.
+func sampleData(t *testing.T) config.Config {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "mcc-edit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	cfg := config.Default()
+	cfg.DataDir = dir
+	for _, dataset := range types.Datasets {
+		body := readFile(t, filepath.Join("..", "..", "data", dataset+".json"))
+		if err := ioutil.WriteFile(cfg.Path(dataset), body, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cfg
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func readDocument(t *testing.T, cfg config.Config, dataset string) *types.Document {
+	t.Helper()
+
+	document, err := types.ReadDocument(cfg.Path(dataset), types.Schemas[dataset])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document
+}
+
+func marshal(t *testing.T, object types.Object) []byte {
+	t.Helper()
+
+	encoded, err := object.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables read by Load.
+const (
+	EnvConfigFile        = "MCC_CONFIG"
+	EnvDataDir           = "MCC_DATA_DIR"
+	EnvUsersFile         = "MCC_USERS_FILE"
+	EnvOrganizationsFile = "MCC_ORGANIZATIONS_FILE"
+	EnvTicketsFile       = "MCC_TICKETS_FILE"
+	EnvReloadInterval    = "MCC_RELOAD_INTERVAL"
+	EnvIndexWorkers      = "MCC_INDEX_WORKERS"
+	EnvSnapshot          = "MCC_SNAPSHOT"
+)
+
+const (
+	DefaultDataDir        = "data"
+	DefaultReloadInterval = 2 * time.Second
+	// NoSnapshot as the snapshot file turns off snapshots turned on by the
+	// YAML file or the environment.
+	NoSnapshot = "none"
+)
+
+// Config says where the datasets are loaded from. A per-dataset file takes
+// precedence over <DataDir>/<dataset>.json.
+type Config struct {
+	DataDir           string `yaml:"data_dir"`
+	UsersFile         string `yaml:"users_file"`
+	OrganizationsFile string `yaml:"organizations_file"`
+	TicketsFile       string `yaml:"tickets_file"`
+	// ReloadInterval is how often the interactive prompt and serve check
+	// the files for changes to reload. Zero turns reloading off.
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+	// IndexWorkers is how many goroutines build the index. Zero means one
+	// per CPU.
+	IndexWorkers int `yaml:"index_workers"`
+	// Snapshot is where the built index is saved, so the next start can
+	// skip loading the JSON files while they haven't changed. There is no
+	// snapshot unless it is set, as the data directory may not be ours to
+	// write to.
+	Snapshot string `yaml:"snapshot"`
+}
+
+func Default() Config {
+	return Config{DataDir: DefaultDataDir, ReloadInterval: DefaultReloadInterval}
+}
+
+// Path returns the JSON file the given dataset is loaded from.
+func (c Config) Path(dataset string) string {
+	var file string
+	switch dataset {
+	case "users":
+		file = c.UsersFile
+	case "organizations":
+		file = c.OrganizationsFile
+	case "tickets":
+		file = c.TicketsFile
+	}
+
+	if file != "" {
+		return file
+	}
+
+	return filepath.Join(c.DataDir, dataset+".json")
+}
+
+// SnapshotPath returns the snapshot file, or "" when snapshots are off.
+func (c Config) SnapshotPath() string {
+	if c.Snapshot == NoSnapshot {
+		return ""
+	}
+	return c.Snapshot
+}
+
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, an optional YAML file, environment variables and the leading
+// command line flags. It returns the arguments left after the flags.
+func Load(args []string, getenv func(string) string, output io.Writer) (Config, []string, error) {
+	var configFile, dataDir, usersFile, organizationsFile, ticketsFile, reloadInterval, indexWorkers, snapshot string
+
+	flags := flag.NewFlagSet("melbourne_code_club_go", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&configFile, "config", "", "YAML config file (env "+EnvConfigFile+")")
+	flags.StringVar(&dataDir, "data-dir", "", "directory holding <dataset>.json files (env "+EnvDataDir+")")
+	flags.StringVar(&usersFile, "users", "", "users JSON file (env "+EnvUsersFile+")")
+	flags.StringVar(&organizationsFile, "organizations", "", "organizations JSON file (env "+EnvOrganizationsFile+")")
+	flags.StringVar(&ticketsFile, "tickets", "", "tickets JSON file (env "+EnvTicketsFile+")")
+	flags.StringVar(&reloadInterval, "reload", "", "how often to check the files for changes, 0 to never reload (env "+EnvReloadInterval+")")
+	flags.StringVar(&indexWorkers, "workers", "", "how many goroutines build the index, 0 for one per CPU (env "+EnvIndexWorkers+")")
+	flags.StringVar(&snapshot, "snapshot", "", "file the index is saved to for a fast start, none for no snapshot (env "+EnvSnapshot+")")
+
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	cfg := Default()
+
+	if configFile == "" {
+		configFile = getenv(EnvConfigFile)
+	}
+
+	if configFile != "" {
+		if err := cfg.readFile(configFile); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	override(&cfg.DataDir, getenv(EnvDataDir), dataDir)
+	override(&cfg.UsersFile, getenv(EnvUsersFile), usersFile)
+	override(&cfg.OrganizationsFile, getenv(EnvOrganizationsFile), organizationsFile)
+	override(&cfg.TicketsFile, getenv(EnvTicketsFile), ticketsFile)
+	override(&cfg.Snapshot, getenv(EnvSnapshot), snapshot)
+
+	interval := ""
+	override(&interval, getenv(EnvReloadInterval), reloadInterval)
+	if interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil || parsed < 0 {
+			return Config{}, nil, fmt.Errorf("Invalid reload interval %q, expected a duration such as 5s", interval)
+		}
+		cfg.ReloadInterval = parsed
+	}
+
+	workers := ""
+	override(&workers, getenv(EnvIndexWorkers), indexWorkers)
+	if workers != "" {
+		parsed, err := strconv.Atoi(workers)
+		if err != nil || parsed < 0 {
+			return Config{}, nil, fmt.Errorf("Invalid number of index workers %q, expected a whole number", workers)
+		}
+		cfg.IndexWorkers = parsed
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+// readFile merges the YAML file into the config. Relative paths in the file
+// are resolved against the file's own directory.
+func (c *Config) readFile(path string) error {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	fileConfig := Config{ReloadInterval: c.ReloadInterval, IndexWorkers: c.IndexWorkers}
+	if err := yaml.Unmarshal(body, &fileConfig); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	if fileConfig.ReloadInterval < 0 {
+		return fmt.Errorf("parsing config file %s: reload_interval can't be negative", path)
+	}
+	if fileConfig.IndexWorkers < 0 {
+		return fmt.Errorf("parsing config file %s: index_workers can't be negative", path)
+	}
+	c.ReloadInterval = fileConfig.ReloadInterval
+	c.IndexWorkers = fileConfig.IndexWorkers
+
+	dir := filepath.Dir(path)
+	override(&c.DataDir, relativeTo(dir, fileConfig.DataDir))
+	override(&c.UsersFile, relativeTo(dir, fileConfig.UsersFile))
+	override(&c.OrganizationsFile, relativeTo(dir, fileConfig.OrganizationsFile))
+	override(&c.TicketsFile, relativeTo(dir, fileConfig.TicketsFile))
+	if fileConfig.Snapshot == NoSnapshot {
+		c.Snapshot = NoSnapshot
+	} else {
+		override(&c.Snapshot, relativeTo(dir, fileConfig.Snapshot))
+	}
+
+	return nil
+}
+
+// override sets the field to the last non-empty value.
+func override(field *string, values ...string) {
+	for _, value := range values {
+		if value != "" {
+			*field = value
+		}
+	}
+}
+
+func relativeTo(dir string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
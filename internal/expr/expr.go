@@ -0,0 +1,143 @@
+package expr
+
+import (
+	"fmt"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/index"
+	"github.com/zendesk/melbourne_code_club_go/internal/search"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// Expr is a query over a single dataset, built from single field lookups
+// combined with AND, OR and NOT.
+type Expr interface {
+	Eval(store *index.Store) ([]types.Record, error)
+	String() string
+}
+
+// Term is a single field lookup.
+type Term struct {
+	Criteria search.Criteria
+}
+
+type And struct {
+	Left  Expr
+	Right Expr
+}
+
+type Or struct {
+	Left  Expr
+	Right Expr
+}
+
+// Not matches every record of Dataset the inner expression doesn't.
+type Not struct {
+	Dataset string
+	Expr    Expr
+}
+
+func (t Term) Eval(store *index.Store) ([]types.Record, error) {
+	return search.Find(store, t.Criteria)
+}
+
+func (t Term) String() string {
+	query := t.Criteria.Query
+	if t.Criteria.Mode == "" || t.Criteria.Mode == search.Exact {
+		return fmt.Sprintf("%s:%v", query.Field, query.Value)
+	}
+	return fmt.Sprintf("%s:%s:%v", query.Field, t.Criteria.Mode, query.Value)
+}
+
+func (a And) Eval(store *index.Store) ([]types.Record, error) {
+	left, err := a.Left.Eval(store)
+	if err != nil {
+		return nil, err
+	}
+	right, err := a.Right.Eval(store)
+	if err != nil {
+		return nil, err
+	}
+
+	inRight := keySet(right)
+
+	var results []types.Record
+	for _, record := range left {
+		if inRight[record.PrimaryKey()] {
+			results = append(results, record)
+		}
+	}
+	return results, nil
+}
+
+func (a And) String() string {
+	return fmt.Sprintf("(%s AND %s)", a.Left, a.Right)
+}
+
+func (o Or) Eval(store *index.Store) ([]types.Record, error) {
+	left, err := o.Left.Eval(store)
+	if err != nil {
+		return nil, err
+	}
+	right, err := o.Right.Eval(store)
+	if err != nil {
+		return nil, err
+	}
+
+	inLeft := keySet(left)
+
+	results := append([]types.Record{}, left...)
+	for _, record := range right {
+		if !inLeft[record.PrimaryKey()] {
+			results = append(results, record)
+		}
+	}
+	return results, nil
+}
+
+func (o Or) String() string {
+	return fmt.Sprintf("(%s OR %s)", o.Left, o.Right)
+}
+
+func (n Not) Eval(store *index.Store) ([]types.Record, error) {
+	excluded, err := n.Expr.Eval(store)
+	if err != nil {
+		return nil, err
+	}
+
+	isExcluded := keySet(excluded)
+
+	var results []types.Record
+	for _, record := range store.Records(n.Dataset) {
+		if !isExcluded[record.PrimaryKey()] {
+			results = append(results, record)
+		}
+	}
+	return results, nil
+}
+
+func (n Not) String() string {
+	return fmt.Sprintf("NOT %s", n.Expr)
+}
+
+func keySet(records []types.Record) map[types.Query]bool {
+	set := make(map[types.Query]bool, len(records))
+	for _, record := range records {
+		set[record.PrimaryKey()] = true
+	}
+	return set
+}
+
+// DatasetOf returns the dataset whose records the expression matches.
+func DatasetOf(expression Expr) string {
+	switch e := expression.(type) {
+	case Term:
+		return e.Criteria.Query.Dataset
+	case And:
+		return DatasetOf(e.Left)
+	case Or:
+		return DatasetOf(e.Left)
+	case Not:
+		return e.Dataset
+	}
+	return ""
+}
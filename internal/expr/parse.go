@@ -0,0 +1,286 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/search"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+	"github.com/zendesk/melbourne_code_club_go/internal/util"
+)
+
+const Syntax = `field:value terms combined with AND, OR, NOT and parentheses.
+Neighbouring terms are ANDed, -term is short for NOT term. Quote values
+holding spaces or brackets, pick a match mode with field:mode:value and
+compare ids and timestamps with field>value, >=, < and <=. field:@missing
+and field:@empty find records without a value:
+  status:open AND (priority:high OR priority:urgent) -tags:Ohio -assignee_id:@missing
+  subject:contains:"korea (north)" OR subject:text:catastrophe
+  due_at<2016-08-01 created_at:between:2016-04-01..2016-04-30`
+
+type tokenKind int
+
+const (
+	tokenTerm tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+	tokenEnd
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	dataset string
+	tokens  []token
+	next    int
+}
+
+// Parse reads an expression over the dataset's fields, such as
+// `status:open AND priority:high -tags:Ohio`. See Syntax.
+func Parse(dataset string, text string) (Expr, error) {
+	if _, ok := types.DataTypes[dataset]; !ok {
+		return nil, fmt.Errorf("Unknown dataset %q", dataset)
+	}
+
+	tokens, err := lex(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{dataset: dataset, tokens: tokens}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEnd {
+		return nil, fmt.Errorf("Unexpected %q at position %d", t.text, t.pos)
+	}
+
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEnd {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenOr {
+		p.take()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.take()
+		case tokenTerm, tokenNot, tokenOpen:
+			// Neighbouring terms are implicitly ANDed
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = And{Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.peek().kind == tokenNot {
+		p.take()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Dataset: p.dataset, Expr: inner}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.take()
+
+	switch t.kind {
+	case tokenOpen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.take(); closing.kind != tokenClose {
+			return nil, fmt.Errorf("Missing ) for ( at position %d", t.pos)
+		}
+		return inner, nil
+	case tokenTerm:
+		return p.parseTerm(t)
+	case tokenEnd:
+		return nil, fmt.Errorf("Unexpected end of query, expected a field:value term")
+	default:
+		return nil, fmt.Errorf("Unexpected %q at position %d, expected a field:value term", t.text, t.pos)
+	}
+}
+
+// comparison is an operator that can stand in for the colon of a term.
+type comparison struct {
+	operator string
+	mode     search.Mode
+}
+
+// comparisons are checked in order, so longer operators come first.
+var comparisons []comparison = []comparison{
+	{">=", search.AtLeast},
+	{"<=", search.AtMost},
+	{">", search.Greater},
+	{"<", search.Less},
+}
+
+func (p *parser) parseTerm(t token) (Expr, error) {
+	separator := strings.IndexAny(t.text, ":<>")
+	if separator < 0 {
+		return nil, fmt.Errorf("Expected field:value at position %d, got %q", t.pos, t.text)
+	}
+
+	field, rest := t.text[:separator], t.text[separator:]
+	if !util.ContainsString(types.DataTypes[p.dataset], field) {
+		return nil, fmt.Errorf("Unknown field %q for %s at position %d", field, p.dataset, t.pos)
+	}
+
+	mode := search.Exact
+	rawValue := strings.TrimPrefix(rest, ":")
+
+	if rest[0] == ':' {
+		if separator := strings.Index(rawValue, ":"); separator > 0 {
+			if parsed, err := search.ParseMode(rawValue[:separator]); err == nil {
+				mode, rawValue = parsed, rawValue[separator+1:]
+			}
+		}
+	} else {
+		for _, comparison := range comparisons {
+			if strings.HasPrefix(rest, comparison.operator) {
+				mode, rawValue = comparison.mode, rest[len(comparison.operator):]
+				break
+			}
+		}
+	}
+
+	if strings.HasPrefix(rawValue, `"`) {
+		unquoted, err := strconv.Unquote(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid quoted value %s at position %d", rawValue, t.pos)
+		}
+		rawValue = unquoted
+	}
+
+	criteria, err := search.NewCriteria(p.dataset, field, mode, rawValue)
+	if err != nil {
+		return nil, fmt.Errorf("%v at position %d", err, t.pos)
+	}
+
+	return Term{Criteria: criteria}, nil
+}
+
+func lex(text string) ([]token, error) {
+	var tokens []token
+
+	for pos := 0; pos < len(text); {
+		switch c := text[pos]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			pos++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "(", pos: pos})
+			pos++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")", pos: pos})
+			pos++
+		case c == '-':
+			tokens = append(tokens, token{kind: tokenNot, text: "-", pos: pos})
+			pos++
+		default:
+			end, err := wordEnd(text, pos)
+			if err != nil {
+				return nil, err
+			}
+
+			word := text[pos:end]
+			kind := tokenTerm
+			switch word {
+			case "AND":
+				kind = tokenAnd
+			case "OR":
+				kind = tokenOr
+			case "NOT":
+				kind = tokenNot
+			}
+
+			tokens = append(tokens, token{kind: kind, text: word, pos: pos})
+			pos = end
+		}
+	}
+
+	return append(tokens, token{kind: tokenEnd, pos: len(text)}), nil
+}
+
+// wordEnd finds where the word starting at pos ends: at whitespace or a
+// bracket outside of double quotes.
+func wordEnd(text string, pos int) (int, error) {
+	start := pos
+	for pos < len(text) {
+		switch text[pos] {
+		case ' ', '\t', '\n', '(', ')':
+			return pos, nil
+		case '"':
+			pos++
+			for pos < len(text) && text[pos] != '"' {
+				if text[pos] == '\\' {
+					pos++
+				}
+				pos++
+			}
+			if pos >= len(text) {
+				return 0, fmt.Errorf("Unterminated quote in %q at position %d", text[start:], start)
+			}
+			pos++
+		default:
+			pos++
+		}
+	}
+	return pos, nil
+}
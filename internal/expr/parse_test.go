@@ -0,0 +1,78 @@
+package expr
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		text string
+		want string
+	}{
+		{`status:open`, `status:open`},
+		{`status:open OR priority:high AND type:task`, `(status:open OR (priority:high AND type:task))`},
+		{`status:open AND priority:high OR type:task`, `((status:open AND priority:high) OR type:task)`},
+		{`(status:open OR priority:high) AND type:task`, `((status:open OR priority:high) AND type:task)`},
+		{`status:open priority:high`, `(status:open AND priority:high)`},
+		{`status:open priority:high OR type:task`, `((status:open AND priority:high) OR type:task)`},
+		{`-tags:Ohio`, `NOT tags:Ohio`},
+		{`status:open -tags:Ohio`, `(status:open AND NOT tags:Ohio)`},
+		{`-(status:open OR status:pending)`, `NOT (status:open OR status:pending)`},
+		{`NOT NOT status:open`, `NOT NOT status:open`},
+		{`NOT -status:open`, `NOT NOT status:open`},
+		{`subject:"A Catastrophe in Korea (North)"`, `subject:A Catastrophe in Korea (North)`},
+		{`subject:contains:"korea (north)"`, `subject:contains:korea (north)`},
+		{`url:"http://initech.zendesk.com"`, `url:http://initech.zendesk.com`},
+		{`subject:contains:"a:b"`, `subject:contains:a:b`},
+		{`subject:"say \"hi\""`, `subject:say "hi"`},
+		{`subject:nope:x`, `subject:nope:x`},
+		{`assignee_id:@missing`, `assignee_id:@missing`},
+		{`submitter_id>5`, `submitter_id:gt:5`},
+		{`submitter_id>=5`, `submitter_id:gte:5`},
+		{`submitter_id<5`, `submitter_id:lt:5`},
+		{`submitter_id<=5`, `submitter_id:lte:5`},
+		{`due_at<2016-08-01`, `due_at:lt:2016-08-01T00:00:00 +00:00`},
+	} {
+		parsed, err := Parse("tickets", c.text)
+		if err != nil {
+			t.Errorf("%s: %v", c.text, err)
+			continue
+		}
+		if got := parsed.String(); got != c.want {
+			t.Errorf("%s: parsed as %s, expected %s", c.text, got, c.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, c := range []struct {
+		text string
+		want string
+	}{
+		{`(status:open`, `Missing ) for ( at position 0`},
+		{`status:open AND (priority:high OR (type:task)`, `Missing ) for ( at position 16`},
+		{`status:open)`, `Unexpected ")" at position 11`},
+		{`status:open AND )`, `Unexpected ")" at position 16, expected a field:value term`},
+		{`()`, `Unexpected ")" at position 1, expected a field:value term`},
+		{`subject:"open`, `Unterminated quote in "subject:\"open" at position 0`},
+		{`status:open subject:"a \" b`, `Unterminated quote in "subject:\"a \\\" b" at position 12`},
+		{`status:open AND`, `Unexpected end of query, expected a field:value term`},
+		{`NOT`, `Unexpected end of query, expected a field:value term`},
+		{`status`, `Expected field:value at position 0, got "status"`},
+		{`status:open nope:1`, `Unknown field "nope" for tickets at position 12`},
+		{`submitter_id>x`, `submitter_id must be a whole number, got "x" at position 0`},
+		{`status>open`, `tickets status doesn't support range matching at position 0`},
+		{`status:open submitter_id:prefix:1`, `tickets submitter_id doesn't support prefix matching at position 12`},
+	} {
+		_, err := Parse("tickets", c.text)
+		if err == nil {
+			t.Errorf("%s: parsed, expected %s", c.text, c.want)
+			continue
+		}
+		if err.Error() != c.want {
+			t.Errorf("%s: got %q, expected %q", c.text, err, c.want)
+		}
+	}
+
+	if _, err := Parse("nope", "status:open"); err == nil || err.Error() != `Unknown dataset "nope"` {
+		t.Errorf("unknown dataset: got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package index
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// BuildStore indexes the records of each dataset like NewStore, with the
+// given number of workers, or one per CPU when workers is 0.
+//
+// Each worker files a contiguous run of every dataset's records into a
+// shard of its own, without locking. The shards are then merged in order,
+// so every index entry lists its records in dataset order just as NewStore
+// would, and the fields' secondary structures are built side by side.
+//
+// Once ctx is done the workers stop and ctx.Err() is returned.
+func BuildStore(ctx context.Context, datasets map[string][]types.Record, workers int) (*Store, error) {
+	workers = workerCount(workers)
+
+	shards := make([]*Store, workers)
+	var wg sync.WaitGroup
+	for i := range shards {
+		shards[i] = newStore(nil)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			shard := shards[i]
+			changes := map[fieldKey]bool{}
+			for _, records := range datasets {
+				from, to := len(records)*i/workers, len(records)*(i+1)/workers
+				for _, record := range records[from:to] {
+					if ctx.Err() != nil {
+						return
+					}
+					shard.file(record, changes)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s := shards[0]
+	s.datasets = datasets
+	for _, shard := range shards[1:] {
+		s.merge(shard)
+	}
+
+	// Every field of a new store has new values
+	changes := map[fieldKey]bool{}
+	for key := range s.values {
+		changes[key] = true
+	}
+	if err := s.refreshWith(ctx, withTextFields(changes), workers); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// merge adds the entries of a shard holding records that come after the
+// store's own. The shard shouldn't be used afterwards, as the store may take
+// over its maps.
+func (s *Store) merge(shard *Store) {
+	for query, records := range shard.index {
+		s.index[query] = append(s.index[query], records...)
+	}
+
+	for primaryKey, keys := range shard.keys {
+		s.keys[primaryKey] = append(s.keys[primaryKey], keys...)
+	}
+
+	for key, counts := range shard.values {
+		merged, ok := s.values[key]
+		if !ok {
+			s.values[key] = counts
+			continue
+		}
+		for value, count := range counts {
+			merged[value] += count
+		}
+	}
+}
+
+// refreshWith is refresh with the fields built by several workers. The
+// structures are only stored once all are built, as building reads the
+// store's maps.
+func (s *Store) refreshWith(ctx context.Context, changes map[fieldKey]bool, workers int) error {
+	keys := make([]fieldKey, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+
+	built := make([]fieldStructures, len(keys))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for position := range next {
+				// Keep taking positions so the sender isn't left blocked
+				if ctx.Err() != nil {
+					continue
+				}
+				built[position] = s.buildField(keys[position], changes[keys[position]])
+			}
+		}()
+	}
+
+	for position := range keys {
+		next <- position
+	}
+	close(next)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for position, key := range keys {
+		s.setField(key, built[position])
+	}
+	return nil
+}
+
+// workerCount turns a configured number of workers into how many to start.
+func workerCount(workers int) int {
+	if workers <= 0 {
+		return runtime.NumCPU()
+	}
+	return workers
+}
+
+// addDataset indexes the records of a dataset the store doesn't hold yet,
+// like BuildStore, and adds them all at once for searches to see. The
+// records are indexed on the side, so searches aren't held up meanwhile.
+func (s *Store) addDataset(ctx context.Context, dataset string, records []types.Record, workers int) error {
+	part, err := BuildStore(ctx, map[string][]types.Record{dataset: records}, workers)
+	if err != nil {
+		return err
+	}
+
+	s.take(dataset, part)
+	return nil
+}
+
+// take adds a dataset indexed in a store of its own, which shouldn't be
+// used afterwards.
+func (s *Store) take(dataset string, part *Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The datasets share no queries, so the part's entries are taken over
+	// whole
+	for query, filed := range part.index {
+		s.index[query] = filed
+	}
+	for primaryKey, keys := range part.keys {
+		s.keys[primaryKey] = keys
+	}
+	for key, counts := range part.values {
+		s.values[key] = counts
+	}
+	for key, strings := range part.strings {
+		s.strings[key] = strings
+	}
+	for key, ordered := range part.ordered {
+		s.ordered[key] = ordered
+	}
+	for key, text := range part.text {
+		// The part has empty text indexes for the other datasets too
+		if key.Dataset == dataset {
+			s.text[key] = text
+		}
+	}
+	s.datasets[dataset] = part.datasets[dataset]
+}
@@ -0,0 +1,115 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// BenchmarkBuildStore indexes the sample data repeated 10 to 1000 times
+// with different numbers of workers. Run it with
+//
+//	go test -run XXX -bench BuildStore -benchmem ./internal/index
+//
+// The sharded build only pays off with several CPUs and enough records to
+// outweigh merging the shards.
+func BenchmarkBuildStore(b *testing.B) {
+	for _, copies := range []int{10, 100, 1000} {
+		datasets := scaledRecords(b, copies)
+
+		for _, workers := range []int{1, 2, 4, 8} {
+			b.Run(fmt.Sprintf("%dx/workers=%d", copies, workers), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := BuildStore(context.Background(), datasets, workers); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestBuildStoreWorkers checks that however the records are shared out, the
+// index lists them in dataset order, as NewStore does.
+func TestBuildStoreWorkers(t *testing.T) {
+	datasets := scaledRecords(t, 3)
+	want := NewStore(datasets)
+
+	for _, workers := range []int{1, 3, 8} {
+		got, err := BuildStore(context.Background(), datasets, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			sameStores(t, want, got)
+			sameSecondary(t, want, got)
+		})
+	}
+}
+
+// scaledRecords returns the sample records repeated the given number of
+// times. Each copy gets its own ids and refers to the users and
+// organizations of the same copy, so the index grows like a bigger export
+// would rather than piling records onto the same keys.
+func scaledRecords(tb testing.TB, copies int) map[string][]types.Record {
+	tb.Helper()
+
+	sample := map[string][]types.Record{}
+	for _, dataset := range types.Datasets {
+		path := filepath.Join("..", "..", "data", dataset+".json")
+		err := types.StreamRecords(context.Background(), dataset, path, func(record types.Record) error {
+			sample[dataset] = append(sample[dataset], record)
+			return nil
+		}, nil)
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	datasets := map[string][]types.Record{}
+	for n := 0; n < copies; n++ {
+		for dataset, records := range sample {
+			for _, record := range records {
+				datasets[dataset] = append(datasets[dataset], copyRecord(record, n))
+			}
+		}
+	}
+	return datasets
+}
+
+func copyRecord(record types.Record, n int) types.Record {
+	offset := n * 100000
+	suffix := fmt.Sprintf("-%d", n)
+
+	switch r := record.(type) {
+	case types.User:
+		r.Id += offset
+		r.ExternalId += suffix
+		r.OrganizationId = offsetId(r.OrganizationId, offset)
+		return r
+	case types.Organization:
+		r.Id += offset
+		r.ExternalId += suffix
+		return r
+	case types.Ticket:
+		r.Id += suffix
+		r.ExternalId += suffix
+		r.SubmitterId += offset
+		r.AssigneeId = offsetId(r.AssigneeId, offset)
+		r.OrganizationId = offsetId(r.OrganizationId, offset)
+		return r
+	}
+	return record
+}
+
+func offsetId(id *int, offset int) *int {
+	if id == nil {
+		return nil
+	}
+	moved := *id + offset
+	return &moved
+}
@@ -0,0 +1,303 @@
+package index
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// BM25 tuning: k1 limits how much repeated terms add, b how strongly long
+// documents are penalised.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+var stopWords map[string]bool = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "if": true, "in": true,
+	"into": true, "is": true, "it": true, "no": true, "not": true, "of": true,
+	"on": true, "or": true, "such": true, "that": true, "the": true, "their": true,
+	"then": true, "there": true, "these": true, "they": true, "this": true,
+	"to": true, "was": true, "will": true, "with": true,
+}
+
+// IsFullText reports whether the field holds free text, which is tokenized
+// into an inverted index on top of its whole-value keys.
+func IsFullText(dataset string, field string) bool {
+	return types.FieldTypeOf(dataset, field) == types.TextField
+}
+
+type posting struct {
+	doc  int
+	freq int
+}
+
+// textIndex is the inverted index of one free text field. Documents are the
+// records holding a value for the field, postings are ordered by document.
+//
+// Records changed after the index was built are added as new documents and
+// removed ones leave a gap, closed once gaps outnumber the documents left.
+// The scores are those of a fresh index, but ties between changed records
+// may be broken differently. docOf, mapping primary keys to documents, is
+// only built for the first removal.
+type textIndex struct {
+	docs          []types.Record
+	lengths       []int
+	live          int
+	totalLength   int
+	averageLength float64
+	postings      map[string][]posting
+	docOf         map[types.Query]int
+}
+
+func newTextIndex() *textIndex {
+	return &textIndex{postings: map[string][]posting{}}
+}
+
+func (t *textIndex) add(record types.Record, text string) {
+	doc := len(t.docs)
+	terms := tokenize(text)
+
+	t.docs = append(t.docs, record)
+	t.lengths = append(t.lengths, len(terms))
+	t.live++
+	t.totalLength += len(terms)
+	if t.docOf != nil {
+		t.docOf[record.PrimaryKey()] = doc
+	}
+
+	freqs := map[string]int{}
+	for _, term := range terms {
+		freqs[term]++
+	}
+	for term, freq := range freqs {
+		t.postings[term] = append(t.postings[term], posting{doc: doc, freq: freq})
+	}
+
+	t.finish()
+}
+
+// remove drops the document of the record with the primary key, filed
+// under text.
+func (t *textIndex) remove(primaryKey types.Query, text string) {
+	if t.docOf == nil {
+		t.docOf = make(map[types.Query]int, len(t.docs))
+		for doc, record := range t.docs {
+			if record != nil {
+				t.docOf[record.PrimaryKey()] = doc
+			}
+		}
+	}
+
+	doc, ok := t.docOf[primaryKey]
+	if !ok {
+		return
+	}
+	delete(t.docOf, primaryKey)
+
+	for _, term := range unique(tokenize(text)) {
+		postings := t.postings[term]
+		kept := postings[:0]
+		for _, p := range postings {
+			if p.doc != doc {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) > 0 {
+			t.postings[term] = kept
+		} else {
+			delete(t.postings, term)
+		}
+	}
+
+	t.docs[doc] = nil
+	t.live--
+	t.totalLength -= t.lengths[doc]
+	t.finish()
+
+	if len(t.docs) > 2*t.live+compactSlack {
+		t.compact()
+	}
+}
+
+// compact numbers the documents left from zero again, keeping their order
+// so postings stay sorted.
+func (t *textIndex) compact() {
+	renumbered := make([]int, len(t.docs))
+	docs := make([]types.Record, 0, t.live)
+	lengths := make([]int, 0, t.live)
+	for doc, record := range t.docs {
+		if record != nil {
+			renumbered[doc] = len(docs)
+			docs = append(docs, record)
+			lengths = append(lengths, t.lengths[doc])
+		}
+	}
+
+	for _, postings := range t.postings {
+		for i := range postings {
+			postings[i].doc = renumbered[postings[i].doc]
+		}
+	}
+	t.docs, t.lengths = docs, lengths
+	t.docOf = nil
+}
+
+func (t *textIndex) finish() {
+	t.averageLength = 0
+	if t.live > 0 {
+		t.averageLength = float64(t.totalLength) / float64(t.live)
+	}
+}
+
+// search returns the documents matching the query, best BM25 score first.
+// Words are ANDed together and OR separates alternatives, so
+// "korea north OR micronesia" is (korea AND north) OR micronesia.
+func (t *textIndex) search(query string) []types.Record {
+	scores := t.score(query)
+
+	docs := make([]int, 0, len(scores))
+	for doc := range scores {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if scores[docs[i]] != scores[docs[j]] {
+			return scores[docs[i]] > scores[docs[j]]
+		}
+		return docs[i] < docs[j]
+	})
+
+	results := make([]types.Record, len(docs))
+	for i, doc := range docs {
+		results[i] = t.docs[doc]
+	}
+	return results
+}
+
+// score returns the BM25 score of each document matching the query.
+func (t *textIndex) score(query string) map[int]float64 {
+	scores := map[int]float64{}
+
+	var queryTerms []string
+	for _, group := range parseTextQuery(query) {
+		for _, doc := range t.matchAll(group) {
+			scores[doc] = 0
+		}
+		queryTerms = append(queryTerms, group...)
+	}
+
+	for _, term := range unique(queryTerms) {
+		postings := t.postings[term]
+		idf := t.idf(len(postings))
+		for _, p := range postings {
+			if _, ok := scores[p.doc]; !ok {
+				continue
+			}
+			tf := float64(p.freq)
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(t.lengths[p.doc])/t.averageLength)
+			scores[p.doc] += idf * tf * (bm25K1 + 1) / (tf + norm)
+		}
+	}
+
+	return scores
+}
+
+// matchAll returns the documents containing every term.
+func (t *textIndex) matchAll(terms []string) []int {
+	var docs []int
+	for i, term := range terms {
+		termDocs := make([]int, len(t.postings[term]))
+		for j, p := range t.postings[term] {
+			termDocs[j] = p.doc
+		}
+
+		if i == 0 {
+			docs = termDocs
+		} else {
+			docs = intersect(docs, termDocs)
+		}
+	}
+	return docs
+}
+
+func (t *textIndex) idf(docsWithTerm int) float64 {
+	n := float64(docsWithTerm)
+	return math.Log(1 + (float64(t.live)-n+0.5)/(n+0.5))
+}
+
+// parseTextQuery splits the query into groups of terms on the OR keyword.
+// Groups left empty once stop words are dropped are ignored.
+func parseTextQuery(query string) [][]string {
+	var groups [][]string
+	var group []string
+
+	for _, word := range strings.Fields(query) {
+		switch word {
+		case "OR":
+			if len(group) > 0 {
+				groups = append(groups, group)
+			}
+			group = nil
+		case "AND":
+		default:
+			group = append(group, tokenize(word)...)
+		}
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+// tokenize splits text into lowercase, stemmed words, leaving out stop words.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		if !stopWords[word] {
+			terms = append(terms, stem(word))
+		}
+	}
+	return terms
+}
+
+// stem strips common English suffixes so "tickets" and "ticket" or "loaded"
+// and "loading" share a term. It's deliberately simple, not a full Porter
+// stemmer.
+func stem(word string) string {
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "ss"):
+		return word
+	}
+
+	for _, suffix := range []string{"ing", "ed", "ly", "s"} {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 3 {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+func unique(values []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -4,60 +4,266 @@ import (
 	"context"
 	"sync"
 
+	"github.com/zendesk/melbourne_code_club_go/internal/config"
 	"github.com/zendesk/melbourne_code_club_go/internal/types"
 )
 
-func LoadAndIndexData(ctx context.Context) types.Index {
-	records := make(chan types.Record, 1)
-	var wg sync.WaitGroup
+// Loader fills a store from the data files one dataset at a time: each
+// dataset is indexed as soon as its own file has been read, so searches on
+// it can start while the others are still loading.
+type Loader struct {
+	store    *Store
+	datasets map[string]*datasetLoad
+	done     chan struct{}
+	err      error
+	// snapshotErr is why the snapshot couldn't be saved.
+	snapshotErr error
+}
+
+// datasetLoad tells how loading one dataset went. settled is closed once
+// the dataset is indexed or has failed, err having been set before if so.
+type datasetLoad struct {
+	settled chan struct{}
+	err     error
+}
 
-	wg.Add(3)
+// StartLoading loads the datasets' files side by side in the background.
+// progress, unless nil, is called as records are loaded and once more, with
+// Indexed set, as each dataset becomes searchable. It is called from
+// several goroutines at once. Once ctx is done loading and indexing stop
+// and the loader fails with ctx.Err().
+//
+// When the configuration names a snapshot file the data is restored from it
+// instead while the data files are those it was taken from. Otherwise the
+// snapshot is written once the files have been loaded, which SnapshotErr
+// tells went wrong.
+func StartLoading(ctx context.Context, cfg config.Config, progress func(types.Progress)) *Loader {
+	l := &Loader{
+		store:    newStore(map[string][]types.Record{}),
+		datasets: map[string]*datasetLoad{},
+		done:     make(chan struct{}),
+	}
+	for _, dataset := range types.Datasets {
+		l.datasets[dataset] = &datasetLoad{settled: make(chan struct{})}
+	}
 
 	go func() {
-		users := types.LoadUsers(ctx)
+		defer close(l.done)
+		l.err = l.load(ctx, cfg, progress)
 
-		for _, u := range users {
-			records <- types.Record(u)
+		// Datasets loading never got to, such as when the snapshot was cut
+		// short, fail with the loader
+		for _, dataset := range types.Datasets {
+			if !l.settled(dataset) {
+				l.settle(dataset, l.err)
+			}
 		}
-
-		wg.Done()
 	}()
 
-	go func() {
-		organizations := types.LoadOrganizations(ctx)
+	return l
+}
+
+func (l *Loader) load(ctx context.Context, cfg config.Config, progress func(types.Progress)) error {
+	path := cfg.SnapshotPath()
+	if path == "" {
+		return l.loadFiles(ctx, cfg, progress)
+	}
 
-		for _, o := range organizations {
-			records <- types.Record(o)
+	// Any snapshot there is only a start; a missing or unreadable one is
+	// replaced once the files have loaded
+	saved, err := openSnapshot(path)
+	var known []sourceFile
+	if err == nil {
+		known = saved.header.Sources
+	}
+
+	// Stamp before loading, so a change made during the load makes the new
+	// snapshot stale
+	sources, stampErr := stampSources(cfg, known)
+
+	if saved != nil {
+		var parts map[string]*Store
+		err := errStaleSnapshot
+		if stampErr == nil && sameContent(sources, known) {
+			parts, err = saved.datasets(ctx, workerCount(cfg.IndexWorkers))
 		}
+		saved.Close()
 
-		wg.Done()
-	}()
+		if err == nil {
+			for _, dataset := range types.Datasets {
+				l.store.take(dataset, parts[dataset])
+				l.settle(dataset, nil)
+				if progress != nil {
+					records := len(l.store.Records(dataset))
+					progress(types.Progress{Dataset: dataset, Records: records, Done: true, Indexed: true})
+				}
+			}
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+	}
 
-	go func() {
-		tickets := types.LoadTickets(ctx)
+	if err := l.loadFiles(ctx, cfg, progress); err != nil {
+		return err
+	}
 
-		for _, u := range tickets {
-			records <- types.Record(u)
+	// The data has loaded either way, a snapshot that couldn't be saved only
+	// makes the next start slower
+	if stampErr != nil {
+		l.snapshotErr = stampErr
+	} else {
+		l.snapshotErr = writeSnapshot(path, sources, l.store)
+	}
+	return nil
+}
+
+// loadFiles loads each dataset's file and indexes the dataset as soon as
+// the file has been read.
+func (l *Loader) loadFiles(ctx context.Context, cfg config.Config, progress func(types.Progress)) error {
+	var wg sync.WaitGroup
+	var errMutex sync.Mutex
+	var loadErr error
+
+	// Keep the first failure; the other loaders still finish, or stop early
+	// when cancelled, before the loader is done.
+	setErr := func(err error) {
+		errMutex.Lock()
+		defer errMutex.Unlock()
+		if loadErr == nil {
+			loadErr = err
 		}
+	}
 
-		wg.Done()
-	}()
+	for _, dataset := range types.Datasets {
+		wg.Add(1)
+		go func(dataset string) {
+			defer wg.Done()
+
+			var last types.Progress
+			report := func(p types.Progress) {
+				last = p
+				if progress != nil {
+					progress(p)
+				}
+			}
 
-	index := types.Index{}
+			// Records are gathered as they are decoded, so no file is held
+			// in memory whole
+			var records []types.Record
+			err := types.StreamRecords(ctx, dataset, cfg.Path(dataset), func(record types.Record) error {
+				records = append(records, record)
+				return nil
+			}, report)
+			if err == nil {
+				err = l.store.addDataset(ctx, dataset, records, cfg.IndexWorkers)
+			}
+			l.settle(dataset, err)
+			if err != nil {
+				setErr(err)
+				return
+			}
+
+			last.Indexed = true
+			report(last)
+		}(dataset)
+	}
+	wg.Wait()
+
+	// A loader cut short by cancelling fails with ctx.Err(), but another may
+	// have failed first for its own reasons
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return loadErr
+}
+
+// settle marks the dataset as indexed, or as failed with err.
+func (l *Loader) settle(dataset string, err error) {
+	state := l.datasets[dataset]
+	state.err = err
+	close(state.settled)
+}
+
+func (l *Loader) settled(dataset string) bool {
+	select {
+	case <-l.datasets[dataset].settled:
+		return true
+	default:
+		return false
+	}
+}
+
+// Store returns the store being filled. It only holds the datasets that are
+// ready.
+func (l *Loader) Store() *Store {
+	return l.store
+}
+
+// Ready returns a channel closed once each of the given datasets can be
+// searched or has failed to load, which Failed tells apart.
+func (l *Loader) Ready(datasets ...string) <-chan struct{} {
+	ready := make(chan struct{})
 	go func() {
-		wg.Wait()
-		close(records)
+		defer close(ready)
+		for _, dataset := range datasets {
+			<-l.datasets[dataset].settled
+		}
 	}()
+	return ready
+}
 
-	for record := range records {
-		for _, query := range record.KeysForIndex() {
-			if len(index[query]) > 0 {
-				index[query] = append(index[query], record)
-			} else {
-				index[query] = []types.Record{record}
-			}
+// Failed waits like Ready and returns why the first of the given datasets
+// that couldn't be loaded failed, or nil when they can all be searched.
+// Datasets that aren't given don't matter, however they went.
+func (l *Loader) Failed(datasets ...string) error {
+	for _, dataset := range datasets {
+		state := l.datasets[dataset]
+		<-state.settled
+		if state.err != nil {
+			return state.err
+		}
+	}
+	return nil
+}
+
+// Pending returns the datasets still loading.
+func (l *Loader) Pending() []string {
+	var pending []string
+	for _, dataset := range types.Datasets {
+		if !l.settled(dataset) {
+			pending = append(pending, dataset)
 		}
 	}
+	return pending
+}
 
-	return index
+// Done returns a channel closed once every dataset is ready or loading has
+// failed.
+func (l *Loader) Done() <-chan struct{} {
+	return l.done
+}
+
+// Err returns why loading failed, once Done is closed.
+func (l *Loader) Err() error {
+	<-l.done
+	return l.err
+}
+
+// SnapshotErr returns why the snapshot couldn't be saved, once Done is
+// closed.
+func (l *Loader) SnapshotErr() error {
+	<-l.done
+	return l.snapshotErr
+}
+
+// LoadAndIndexData loads and indexes every dataset, waiting until all are
+// ready. See StartLoading; a snapshot that couldn't be saved isn't reported.
+func LoadAndIndexData(ctx context.Context, cfg config.Config, progress func(types.Progress)) (*Store, error) {
+	loader := StartLoading(ctx, cfg, progress)
+	if err := loader.Err(); err != nil {
+		return nil, err
+	}
+	return loader.Store(), nil
 }
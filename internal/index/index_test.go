@@ -0,0 +1,172 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/config"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+	"github.com/zendesk/melbourne_code_club_go/internal/util"
+)
+
+func TestLoaderWithBrokenFile(t *testing.T) {
+	cfg := sampleData(t)
+	if err := ioutil.WriteFile(cfg.Path("users"), []byte(`[{"_id": 1, "name": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loading := StartLoading(context.Background(), cfg, nil)
+
+	// Datasets only ever leave the pending list
+	pending := loading.Pending()
+	for waiting := true; waiting; {
+		select {
+		case <-loading.Done():
+			waiting = false
+		default:
+		}
+
+		now := loading.Pending()
+		for _, dataset := range now {
+			if !util.ContainsString(pending, dataset) {
+				t.Fatalf("%s is pending again", dataset)
+			}
+		}
+		pending = now
+	}
+	if pending := loading.Pending(); len(pending) != 0 {
+		t.Errorf("%v are still pending once loading is done", pending)
+	}
+
+	if err := loading.Failed("organizations"); err != nil {
+		t.Errorf("organizations failed: %v", err)
+	}
+	if err := loading.Failed("organizations", "tickets"); err != nil {
+		t.Errorf("organizations and tickets failed: %v", err)
+	}
+	if len(loading.Store().Records("organizations")) == 0 {
+		t.Error("organizations can't be searched")
+	}
+
+	var loadErr *types.LoadError
+	if err := loading.Failed("users"); !errors.As(err, &loadErr) || loadErr.File != cfg.Path("users") {
+		t.Errorf("users failed with %v, expected a LoadError for %s", err, cfg.Path("users"))
+	}
+	if err := loading.Failed("organizations", "users"); !errors.As(err, &loadErr) {
+		t.Errorf("organizations and users failed with %v, expected a LoadError", err)
+	}
+	if err := loading.Err(); !errors.As(err, &loadErr) {
+		t.Errorf("loading failed with %v, expected a LoadError", err)
+	}
+
+	select {
+	case <-loading.Ready(types.Datasets...):
+	case <-time.After(time.Second):
+		t.Error("datasets aren't ready once loading is done")
+	}
+}
+
+func TestLoaderCancelledBeforeStart(t *testing.T) {
+	cfg := sampleData(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	loading := StartLoading(ctx, cfg, nil)
+	if err := loading.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("loading failed with %v, expected context.Canceled", err)
+	}
+	for _, dataset := range types.Datasets {
+		if err := loading.Failed(dataset); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s failed with %v, expected context.Canceled", dataset, err)
+		}
+	}
+}
+
+func TestLoaderCancelledWhileDecoding(t *testing.T) {
+	cfg := sampleData(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	goroutines := runtime.NumGoroutine()
+
+	// Cancelled from the users' progress, so users can't have finished
+	var mu sync.Mutex
+	decoded := 0
+	loading := StartLoading(ctx, cfg, func(p types.Progress) {
+		if p.Dataset != "users" {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		decoded = p.Records
+		if p.Records == 10 {
+			cancel()
+		}
+	})
+
+	if err := loading.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("loading failed with %v, expected context.Canceled", err)
+	}
+	if err := loading.Failed("users"); !errors.Is(err, context.Canceled) {
+		t.Errorf("users failed with %v, expected context.Canceled", err)
+	}
+	if pending := loading.Pending(); len(pending) != 0 {
+		t.Errorf("%v are still pending once loading is done", pending)
+	}
+	mu.Lock()
+	if decoded > 11 {
+		t.Errorf("%d users were decoded after cancelling at 10", decoded)
+	}
+	mu.Unlock()
+	if records := loading.Store().Records("users"); len(records) != 0 {
+		t.Errorf("%d users can be searched after cancelling", len(records))
+	}
+
+	// Every goroutine loading started has returned
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if now := runtime.NumGoroutine(); now > goroutines {
+		t.Errorf("%d goroutines are left running, %d before loading", now, goroutines)
+	}
+}
+
+func TestBuildStoreCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := BuildStore(ctx, scaledRecords(t, 3), 4); !errors.Is(err, context.Canceled) {
+		t.Errorf("BuildStore failed with %v, expected context.Canceled", err)
+	}
+}
+
+// sampleData copies the sample data to a temporary directory.
+func sampleData(t *testing.T) config.Config {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "mcc-data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	cfg := config.Default()
+	cfg.DataDir = dir
+	for _, dataset := range types.Datasets {
+		body, err := ioutil.ReadFile(filepath.Join("..", "..", "data", dataset+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(cfg.Path(dataset), body, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,240 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/config"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// The benchmarks compare the streaming loader with the way the data used to
+// be loaded: each file read whole and unmarshalled into a slice before its
+// first record went on to be indexed. Run them with
+//
+//	go test -run XXX -bench Load -benchmem ./internal/index
+//
+// The sample data is repeated to make files big enough to tell them apart.
+const sampleCopies = 50
+
+func BenchmarkLoadStreaming(b *testing.B) {
+	cfg := largeData(b)
+	b.ReportAllocs()
+	defer reportPeakHeap(b)()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := LoadAndIndexData(context.Background(), cfg, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadWholeFiles(b *testing.B) {
+	cfg := largeData(b)
+	b.ReportAllocs()
+	defer reportPeakHeap(b)()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := loadWholeFiles(cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The decode benchmarks leave out indexing, which keeps every record, so
+// only the loaders' own memory use is measured.
+func BenchmarkDecodeStreaming(b *testing.B) {
+	cfg := largeData(b)
+	b.ReportAllocs()
+	defer reportPeakHeap(b)()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, dataset := range types.Datasets {
+			err := types.StreamRecords(context.Background(), dataset, cfg.Path(dataset), func(types.Record) error {
+				return nil
+			}, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkDecodeWholeFiles(b *testing.B) {
+	cfg := largeData(b)
+	b.ReportAllocs()
+	defer reportPeakHeap(b)()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, dataset := range types.Datasets {
+			if _, err := readWholeFile(dataset, cfg.Path(dataset)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// loadWholeFiles is LoadAndIndexData as it was before the loaders streamed.
+func loadWholeFiles(cfg config.Config) (*Store, error) {
+	records := make(chan types.Record, 1)
+	var wg sync.WaitGroup
+	errs := make(chan error, len(types.Datasets))
+
+	for _, dataset := range types.Datasets {
+		wg.Add(1)
+		go func(dataset string) {
+			defer wg.Done()
+			loaded, err := readWholeFile(dataset, cfg.Path(dataset))
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, record := range loaded {
+				records <- record
+			}
+		}(dataset)
+	}
+
+	go func() {
+		wg.Wait()
+		close(records)
+	}()
+
+	datasets := map[string][]types.Record{}
+	for record := range records {
+		dataset := record.PrimaryKey().Dataset
+		datasets[dataset] = append(datasets[dataset], record)
+	}
+
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+		return NewStore(datasets), nil
+	}
+}
+
+// readWholeFile reads the file at path in one go and checks and decodes
+// its records only once it has all of them.
+func readWholeFile(dataset string, path string) ([]types.Record, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raws []json.RawMessage
+	if err := json.Unmarshal(body, &raws); err != nil {
+		return nil, err
+	}
+
+	schema := types.Schemas[dataset]
+	records := make([]types.Record, 0, len(raws))
+	for i, raw := range raws {
+		if problems := schema.Validate(raw); len(problems) > 0 {
+			return nil, fmt.Errorf("record %d: %s", i, strings.Join(problems, ", "))
+		}
+		record, err := types.DecodeRecord(dataset, raw)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// reportPeakHeap samples the heap while the benchmark runs and reports the
+// most it held, which B/op doesn't show: streaming allocates as much in
+// total but lets go of it sooner.
+func reportPeakHeap(b *testing.B) func() {
+	b.Helper()
+	runtime.GC()
+
+	var peak uint64
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		var stats runtime.MemStats
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > peak {
+				peak = stats.HeapAlloc
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		b.ReportMetric(float64(peak)/(1<<20), "peak-MB")
+	}
+}
+
+// largeData writes the sample data files, each repeated sampleCopies times,
+// to a temporary directory.
+func largeData(b *testing.B) config.Config {
+	b.Helper()
+
+	dir, err := ioutil.TempDir("", "mcc-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { os.RemoveAll(dir) })
+
+	cfg := config.Default()
+	cfg.DataDir = dir
+	// Every run should load the files rather than a snapshot of the last
+	cfg.Snapshot = config.NoSnapshot
+
+	for _, dataset := range types.Datasets {
+		sample, err := ioutil.ReadFile(filepath.Join("..", "..", "data", dataset+".json"))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		var elements []json.RawMessage
+		if err := json.Unmarshal(sample, &elements); err != nil {
+			b.Fatal(err)
+		}
+
+		var body bytes.Buffer
+		body.WriteString("[\n")
+		for n := 0; n < sampleCopies; n++ {
+			for i, element := range elements {
+				if n > 0 || i > 0 {
+					body.WriteString(",\n")
+				}
+				body.Write(element)
+			}
+		}
+		body.WriteString("\n]\n")
+
+		if err := ioutil.WriteFile(cfg.Path(dataset), body.Bytes(), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return cfg
+}
@@ -0,0 +1,142 @@
+package index
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// IsRangeField reports whether the field supports range lookups: numbers
+// and timestamps, the latter compared at one second resolution.
+func IsRangeField(dataset string, field string) bool {
+	switch types.FieldTypeOf(dataset, field) {
+	case types.IntField, types.TimeField:
+		return true
+	}
+	return false
+}
+
+// Bound is one end of a range lookup.
+type Bound struct {
+	Key       float64
+	Inclusive bool
+}
+
+// orderedValues holds the distinct values indexed under one field sorted by
+// their numeric key: the number itself, or the Unix time of a timestamp.
+type orderedValues struct {
+	keys   []float64
+	values []interface{}
+}
+
+func newOrderedValues(keys map[interface{}]float64) *orderedValues {
+	o := &orderedValues{
+		keys:   make([]float64, 0, len(keys)),
+		values: make([]interface{}, 0, len(keys)),
+	}
+	for value := range keys {
+		o.values = append(o.values, value)
+	}
+	sort.Slice(o.values, func(i, j int) bool {
+		return keys[o.values[i]] < keys[o.values[j]]
+	})
+	for _, value := range o.values {
+		o.keys = append(o.keys, keys[value])
+	}
+	return o
+}
+
+// add inserts a value the field didn't hold yet, after those with the same
+// key. Like stringValues, the slices are replaced rather than modified.
+func (o *orderedValues) add(value interface{}, key float64) {
+	at := sort.Search(len(o.keys), func(i int) bool {
+		return o.keys[i] > key
+	})
+
+	keys := make([]float64, len(o.keys)+1)
+	copy(keys, o.keys[:at])
+	keys[at] = key
+	copy(keys[at+1:], o.keys[at:])
+
+	values := make([]interface{}, len(o.values)+1)
+	copy(values, o.values[:at])
+	values[at] = value
+	copy(values[at+1:], o.values[at:])
+
+	o.keys, o.values = keys, values
+}
+
+// remove drops a value the field no longer holds.
+func (o *orderedValues) remove(value interface{}, key float64) {
+	at := sort.Search(len(o.keys), func(i int) bool {
+		return o.keys[i] >= key
+	})
+	for at < len(o.keys) && o.keys[at] == key && o.values[at] != value {
+		at++
+	}
+	if at == len(o.keys) || o.values[at] != value {
+		return
+	}
+
+	keys := make([]float64, len(o.keys)-1)
+	copy(keys, o.keys[:at])
+	copy(keys[at:], o.keys[at+1:])
+
+	values := make([]interface{}, len(o.values)-1)
+	copy(values, o.values[:at])
+	copy(values[at:], o.values[at+1:])
+
+	o.keys, o.values = keys, values
+}
+
+// between returns the values within the bounds; a nil bound leaves that
+// end of the range open.
+func (o *orderedValues) between(lower *Bound, upper *Bound) []interface{} {
+	from, to := 0, len(o.keys)
+
+	if lower != nil {
+		from = sort.Search(len(o.keys), func(i int) bool {
+			if lower.Inclusive {
+				return o.keys[i] >= lower.Key
+			}
+			return o.keys[i] > lower.Key
+		})
+	}
+
+	if upper != nil {
+		to = sort.Search(len(o.keys), func(i int) bool {
+			if upper.Inclusive {
+				return o.keys[i] > upper.Key
+			}
+			return o.keys[i] >= upper.Key
+		})
+	}
+
+	if from >= to {
+		return nil
+	}
+	return o.values[from:to]
+}
+
+// RangeKey converts an indexed value of the field, or a query value coerced
+// with types.Field.Coerce, into the key it is ordered by.
+func RangeKey(dataset string, field string, value interface{}) (float64, error) {
+	if types.FieldTypeOf(dataset, field) == types.TimeField {
+		text, ok := value.(string)
+		if !ok {
+			return 0, fmt.Errorf("%s %s needs a timestamp, got %v", dataset, field, value)
+		}
+		t, err := types.ParseTime(text)
+		if err != nil {
+			return 0, err
+		}
+		return float64(t.Unix()), nil
+	}
+
+	number, ok := value.(int)
+	if !ok {
+		return 0, fmt.Errorf("%s %s needs a whole number, got %v", dataset, field, value)
+	}
+	return float64(number), nil
+}
@@ -0,0 +1,150 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/config"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// Live holds the store built from the data files and replaces it with a
+// fresh one when Watch sees the files change. Searches take the current
+// store once and keep using it, so a reload never changes the data under a
+// search in flight.
+type Live struct {
+	cfg     config.Config
+	current atomic.Value
+	stamps  map[string]fileStamp
+	loading *Loader
+}
+
+// fileStamp is what polling compares to tell a data file has changed.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// StartLive starts loading the data files like StartLoading, remembering
+// their modification times for Watch, and returns at once. Until loading is
+// done the store fills up a dataset at a time; Loading tells when the
+// datasets a search needs are ready.
+func StartLive(ctx context.Context, cfg config.Config, progress func(types.Progress)) *Live {
+	// Stamp first, so a change made during the load triggers a reload
+	stamps := stampFiles(cfg)
+
+	live := &Live{cfg: cfg, stamps: stamps, loading: StartLoading(ctx, cfg, progress)}
+	live.current.Store(live.loading.Store())
+	return live
+}
+
+// LoadLive is StartLive waiting until every dataset is ready.
+func LoadLive(ctx context.Context, cfg config.Config, progress func(types.Progress)) (*Live, error) {
+	live := StartLive(ctx, cfg, progress)
+	if err := live.loading.Err(); err != nil {
+		return nil, err
+	}
+	return live, nil
+}
+
+// Loading returns the loader of the first load.
+func (l *Live) Loading() *Loader {
+	return l.loading
+}
+
+// Store returns the current store.
+func (l *Live) Store() *Store {
+	return l.current.Load().(*Store)
+}
+
+// Watch polls the data files every interval until ctx is done. When one has
+// changed it builds a new store in the background of searches and swaps it
+// in, logging the outcome to log. A failed reload keeps the current store.
+func (l *Live) Watch(ctx context.Context, interval time.Duration, log io.Writer) {
+	// Reloading only starts once the first load has worked
+	select {
+	case <-l.loading.Done():
+		if l.loading.Err() != nil {
+			return
+		}
+	case <-ctx.Done():
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stamps := stampFiles(l.cfg)
+		if sameStamps(stamps, l.stamps) {
+			continue
+		}
+		// Remember the new stamps even if the reload fails, so a broken
+		// file is reported once rather than on every poll
+		l.stamps = stamps
+
+		started := time.Now()
+		loading := StartLoading(ctx, l.cfg, nil)
+		err := loading.Err()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(log, "Reloading data failed, keeping the previous data: %v\n", err)
+			continue
+		}
+
+		store := loading.Store()
+		l.current.Store(store)
+		fmt.Fprintf(log, "Reloaded data in %v: %s\n", time.Since(started).Round(time.Millisecond), recordCounts(store))
+		if err := loading.SnapshotErr(); err != nil {
+			fmt.Fprintf(log, "Failed to save the index snapshot: %v\n", err)
+		}
+	}
+}
+
+func stampFiles(cfg config.Config) map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	for _, dataset := range types.Datasets {
+		path := cfg.Path(dataset)
+		// A missing file has the zero stamp; the reload reports it
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		} else {
+			stamps[path] = fileStamp{}
+		}
+	}
+	return stamps
+}
+
+func sameStamps(a map[string]fileStamp, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, stamp := range a {
+		other, ok := b[path]
+		if !ok || !stamp.modTime.Equal(other.modTime) || stamp.size != other.size {
+			return false
+		}
+	}
+	return true
+}
+
+func recordCounts(store *Store) string {
+	counts := make([]string, len(types.Datasets))
+	for i, dataset := range types.Datasets {
+		counts[i] = fmt.Sprintf("%d %s", len(store.Records(dataset)), dataset)
+	}
+	return strings.Join(counts, ", ")
+}
@@ -0,0 +1,267 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/config"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+	"github.com/zendesk/melbourne_code_club_go/internal/util"
+)
+
+// snapshotVersion changes whenever the records or the way they are indexed
+// do, so snapshots written before are rebuilt rather than misread.
+const snapshotVersion = 1
+
+var errStaleSnapshot = errors.New("Snapshot doesn't match the data files")
+
+func init() {
+	gob.Register(types.User{})
+	gob.Register(types.Organization{})
+	gob.Register(types.Ticket{})
+	gob.Register(types.Special(""))
+}
+
+// A snapshot file holds a snapshotHeader followed by a snapshotBody, so a
+// stale snapshot is told apart without decoding the records.
+type snapshotHeader struct {
+	Version int
+	Sources []sourceFile
+}
+
+// sourceFile identifies the content of a data file a snapshot was built
+// from. The hash is only worked out again when the modification time has
+// changed, as a copy or a touch leaves the content as it was.
+type sourceFile struct {
+	Dataset string
+	Path    string
+	Size    int64
+	ModTime int64
+	Hash    []byte
+}
+
+type snapshotBody struct {
+	Datasets []snapshotDataset
+}
+
+type snapshotDataset struct {
+	Name    string
+	Records []types.Record
+	// Empty holds the EmptyFields of each record, which gob would lose.
+	Empty    []uint64
+	Postings []snapshotPosting
+}
+
+// snapshotPosting lists the positions in the dataset of the records filed
+// under a query, in dataset order.
+type snapshotPosting struct {
+	Query   types.Query
+	Records []int32
+}
+
+// stampSources describes the data files as they are now, to stamp a
+// snapshot of the data about to be loaded from them. Files whose size and
+// modification time are those noted in known keep the hash noted with them,
+// so each file is read to hash it at most once per load, and only when it
+// has changed.
+func stampSources(cfg config.Config, known []sourceFile) ([]sourceFile, error) {
+	sources := make([]sourceFile, len(types.Datasets))
+	for i, dataset := range types.Datasets {
+		path, err := filepath.Abs(cfg.Path(dataset))
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		source := sourceFile{Dataset: dataset, Path: path, Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+		for _, other := range known {
+			if other.Dataset == source.Dataset && other.Path == source.Path && other.Size == source.Size && other.ModTime == source.ModTime {
+				source.Hash = other.Hash
+			}
+		}
+		if source.Hash == nil {
+			if source.Hash, err = hashFile(path); err != nil {
+				return nil, err
+			}
+		}
+		sources[i] = source
+	}
+	return sources, nil
+}
+
+// sameContent reports whether the files hold what they did when stamped,
+// however their modification times moved since.
+func sameContent(a []sourceFile, b []sourceFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Dataset != b[i].Dataset || a[i].Path != b[i].Path || a[i].Size != b[i].Size || !bytes.Equal(a[i].Hash, b[i].Hash) {
+			return false
+		}
+	}
+	return true
+}
+
+func hashFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// writeSnapshot saves the records of the store and where each is filed to
+// path, stamped with the files they were loaded from. Like data files, the
+// snapshot is replaced atomically.
+func writeSnapshot(path string, sources []sourceFile, store *Store) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	body := snapshotBody{}
+	for _, dataset := range types.Datasets {
+		schema := types.Schemas[dataset]
+		records := store.datasets[dataset]
+
+		saved := snapshotDataset{Name: dataset, Records: records, Empty: make([]uint64, len(records))}
+		positions := map[types.Query][]int32{}
+		var order []types.Query
+		for i, record := range records {
+			saved.Empty[i] = schema.EmptyFields(record)
+			for _, query := range record.KeysForIndex() {
+				if _, ok := positions[query]; !ok {
+					order = append(order, query)
+				}
+				positions[query] = append(positions[query], int32(i))
+			}
+		}
+		for _, query := range order {
+			saved.Postings = append(saved.Postings, snapshotPosting{Query: query, Records: positions[query]})
+		}
+
+		body.Datasets = append(body.Datasets, saved)
+	}
+
+	return util.WriteFileAtomically(path, 0644, func(w io.Writer) error {
+		encoder := gob.NewEncoder(w)
+		if err := encoder.Encode(snapshotHeader{Version: snapshotVersion, Sources: sources}); err != nil {
+			return err
+		}
+		return encoder.Encode(body)
+	})
+}
+
+// snapshotReader reads a snapshot file: its header when opened, so the
+// sources can be compared before the records are decoded.
+type snapshotReader struct {
+	file    *os.File
+	decoder *gob.Decoder
+	header  snapshotHeader
+}
+
+// openSnapshot reads the header of the snapshot at path. It fails with
+// errStaleSnapshot when the snapshot was written by another version.
+func openSnapshot(path string) (*snapshotReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &snapshotReader{file: file, decoder: gob.NewDecoder(bufio.NewReader(file))}
+	if err := r.decoder.Decode(&r.header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if r.header.Version != snapshotVersion {
+		file.Close()
+		return nil, errStaleSnapshot
+	}
+	return r, nil
+}
+
+func (r *snapshotReader) Close() error {
+	return r.file.Close()
+}
+
+// datasets rebuilds the saved datasets, each in a store of its own.
+func (r *snapshotReader) datasets(ctx context.Context, workers int) (map[string]*Store, error) {
+	var body snapshotBody
+	if err := r.decoder.Decode(&body); err != nil {
+		return nil, err
+	}
+
+	parts := map[string]*Store{}
+	for _, saved := range body.Datasets {
+		part, err := saved.restore(ctx, workers)
+		if err != nil {
+			return nil, err
+		}
+		parts[saved.Name] = part
+	}
+	if len(parts) != len(types.Datasets) {
+		return nil, fmt.Errorf("Snapshot holds %d datasets, expected %d", len(parts), len(types.Datasets))
+	}
+
+	return parts, nil
+}
+
+// restore files the records under the saved postings, which gives the same
+// index as filing them afresh without working out their keys.
+func (saved snapshotDataset) restore(ctx context.Context, workers int) (*Store, error) {
+	schema, ok := types.Schemas[saved.Name]
+	if !ok || len(saved.Empty) != len(saved.Records) {
+		return nil, fmt.Errorf("Snapshot of %q is damaged", saved.Name)
+	}
+
+	records := make([]types.Record, len(saved.Records))
+	primaryKeys := make([]types.Query, len(saved.Records))
+	for i, record := range saved.Records {
+		records[i] = schema.WithEmptyFields(record, saved.Empty[i])
+		primaryKeys[i] = records[i].PrimaryKey()
+	}
+
+	part := newStore(map[string][]types.Record{saved.Name: records})
+	changes := map[fieldKey]bool{}
+	for _, p := range saved.Postings {
+		filed := make([]types.Record, len(p.Records))
+		for i, position := range p.Records {
+			if int(position) >= len(records) {
+				return nil, fmt.Errorf("Snapshot of %q is damaged", saved.Name)
+			}
+			filed[i] = records[position]
+			part.keys[primaryKeys[position]] = append(part.keys[primaryKeys[position]], p.Query)
+		}
+		part.index[p.Query] = filed
+
+		key := fieldKey{Dataset: p.Query.Dataset, Field: p.Query.Field}
+		counts, ok := part.values[key]
+		if !ok {
+			counts = map[interface{}]int{}
+			part.values[key] = counts
+		}
+		counts[p.Query.Value] += len(filed)
+		changes[key] = true
+	}
+
+	if err := part.refreshWith(ctx, withTextFields(changes), workers); err != nil {
+		return nil, err
+	}
+	return part, nil
+}
@@ -0,0 +1,207 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/config"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	cfg := snapshotData(t)
+
+	fresh, restored := loadWithSnapshot(t, cfg)
+	if restored {
+		t.Fatal("first load restored a snapshot that wasn't written yet")
+	}
+	if _, err := os.Stat(cfg.SnapshotPath()); err != nil {
+		t.Fatalf("snapshot wasn't written: %v", err)
+	}
+
+	again, restored := loadWithSnapshot(t, cfg)
+	if !restored {
+		t.Fatal("second load didn't restore the snapshot")
+	}
+	sameStores(t, fresh, again)
+
+	// The fields gob can't tell from missing ones
+	users := types.Schemas["users"]
+	for _, c := range []struct {
+		field string
+		value interface{}
+		id    int
+	}{
+		{"alias", types.Empty, 1},
+		{"tags", types.Empty, 1},
+		{"verified", false, 1},
+		{"email", types.Missing, 2},
+	} {
+		found := again.Lookup(types.Query{Dataset: "users", Field: c.field, Value: c.value})
+		if !containsId(users, found, c.id) {
+			t.Errorf("users %s %v doesn't find user %d after restoring", c.field, c.value, c.id)
+		}
+	}
+	user, _ := users.Field("verified")
+	if value := users.Value(again.Lookup(types.Query{Dataset: "users", Field: "_id", Value: 1})[0], user); value != false {
+		t.Errorf("user 1 verified is %v after restoring, expected false", value)
+	}
+}
+
+func TestSnapshotStale(t *testing.T) {
+	cfg := snapshotData(t)
+	loadWithSnapshot(t, cfg)
+
+	// A touched file still holds what the snapshot was taken from
+	path := cfg.Path("users")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, restored := loadWithSnapshot(t, cfg); !restored {
+		t.Error("touching a file made the snapshot stale")
+	}
+
+	// Same size, different content
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(body[bytes.Index(body, []byte("Francisca")):], "Frandisca")
+	if err := ioutil.WriteFile(path, body, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	store, restored := loadWithSnapshot(t, cfg)
+	if restored {
+		t.Fatal("a changed file didn't make the snapshot stale")
+	}
+	if len(store.Lookup(types.Query{Dataset: "users", Field: "name", Value: "Frandisca Rasmussen"})) != 1 {
+		t.Error("the reload doesn't hold the changed record")
+	}
+
+	// The snapshot was written again from the changed file
+	store, restored = loadWithSnapshot(t, cfg)
+	if !restored {
+		t.Fatal("the snapshot wasn't written again after the change")
+	}
+	if len(store.Lookup(types.Query{Dataset: "users", Field: "name", Value: "Frandisca Rasmussen"})) != 1 {
+		t.Error("the new snapshot doesn't hold the changed record")
+	}
+}
+
+// sameStores compares the records of each dataset and the lookup of every
+// value they are filed under, @missing and @empty included.
+func sameStores(t *testing.T, want *Store, got *Store) {
+	t.Helper()
+
+	for _, dataset := range types.Datasets {
+		if !reflect.DeepEqual(want.Records(dataset), got.Records(dataset)) {
+			t.Errorf("%s records differ", dataset)
+		}
+
+		queries := map[types.Query]bool{}
+		for _, field := range types.Schemas[dataset].SearchableFields() {
+			queries[types.Query{Dataset: dataset, Field: field, Value: types.Missing}] = true
+			queries[types.Query{Dataset: dataset, Field: field, Value: types.Empty}] = true
+		}
+		for _, record := range want.Records(dataset) {
+			for _, query := range record.KeysForIndex() {
+				queries[query] = true
+			}
+		}
+
+		for query := range queries {
+			if !reflect.DeepEqual(want.Lookup(query), got.Lookup(query)) {
+				t.Errorf("%s %s %v finds different records", query.Dataset, query.Field, query.Value)
+			}
+		}
+	}
+}
+
+// loadWithSnapshot loads the data and tells whether it came from the
+// snapshot, which reports no file sizes as it reads no files.
+func loadWithSnapshot(t *testing.T, cfg config.Config) (*Store, bool) {
+	t.Helper()
+
+	// Progress is reported from each dataset's goroutine
+	var mu sync.Mutex
+	var size int64
+	loading := StartLoading(context.Background(), cfg, func(p types.Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		size += p.Size
+	})
+	if err := loading.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := loading.SnapshotErr(); err != nil {
+		t.Fatal(err)
+	}
+	return loading.Store(), size == 0
+}
+
+// snapshotData writes the sample data to a temporary directory, the first
+// user with a blank alias, no tags and verified false, the second without
+// an email.
+func snapshotData(t *testing.T) config.Config {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "mcc-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	cfg := config.Default()
+	cfg.DataDir = dir
+	cfg.Snapshot = filepath.Join(dir, "index.snapshot")
+
+	for _, dataset := range types.Datasets {
+		body, err := ioutil.ReadFile(filepath.Join("..", "..", "data", dataset+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if dataset == "users" {
+			var users []map[string]interface{}
+			if err := json.Unmarshal(body, &users); err != nil {
+				t.Fatal(err)
+			}
+			users[0]["alias"] = ""
+			users[0]["tags"] = []string{}
+			users[0]["verified"] = false
+			delete(users[1], "email")
+			if body, err = json.MarshalIndent(users, "", "  "); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := ioutil.WriteFile(cfg.Path(dataset), body, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return cfg
+}
+
+func containsId(schema *types.Schema, records []types.Record, id int) bool {
+	field, _ := schema.Field("_id")
+	for _, record := range records {
+		if schema.Value(record, field) == id {
+			return true
+		}
+	}
+	return false
+}
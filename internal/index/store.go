@@ -0,0 +1,527 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// Store is the exact match index together with the secondary lookup
+// structures built from its keys. Records can be inserted, updated and
+// deleted while searches run: reads go through the methods, which share a
+// lock with the updates, and the slices they return are never modified
+// afterwards. A change only touches the structures of the values it adds
+// or removes, though a value appearing in or disappearing from a field
+// costs a copy of that field's sorted values.
+type Store struct {
+	mu       sync.RWMutex
+	index    types.Index
+	datasets map[string][]types.Record
+	// keys holds the queries each record is filed under by primary key, so
+	// its entries can be removed without scanning the index.
+	keys map[types.Query][]types.Query
+	// values counts the entries of each distinct value of a field. Loads
+	// build the secondary structures of a field from it, and single changes
+	// use it to tell when a value appears or disappears.
+	values  map[fieldKey]map[interface{}]int
+	strings map[fieldKey]*stringValues
+	text    map[fieldKey]*textIndex
+	ordered map[fieldKey]*orderedValues
+}
+
+var (
+	ErrNotFound = errors.New("Record not found")
+	ErrExists   = errors.New("Record already exists")
+)
+
+// NewStore indexes the records of each dataset, kept in the given order.
+// BuildStore does the same work spread over several goroutines.
+func NewStore(datasets map[string][]types.Record) *Store {
+	s := newStore(datasets)
+
+	changes := map[fieldKey]bool{}
+	for _, records := range datasets {
+		for _, record := range records {
+			s.file(record, changes)
+		}
+	}
+
+	s.refresh(withTextFields(changes))
+	return s
+}
+
+func newStore(datasets map[string][]types.Record) *Store {
+	return &Store{
+		index:    types.Index{},
+		datasets: datasets,
+		keys:     map[types.Query][]types.Query{},
+		values:   map[fieldKey]map[interface{}]int{},
+		strings:  map[fieldKey]*stringValues{},
+		text:     map[fieldKey]*textIndex{},
+		ordered:  map[fieldKey]*orderedValues{},
+	}
+}
+
+// withTextFields adds every text field to the changes of a new store, as
+// text fields are searchable even when no record has a value yet.
+func withTextFields(changes map[fieldKey]bool) map[fieldKey]bool {
+	for dataset, schema := range types.Schemas {
+		for _, field := range schema.Fields {
+			if field.Type == types.TextField {
+				changes[fieldKey{dataset, field.Name}] = true
+			}
+		}
+	}
+	return changes
+}
+
+// Insert adds a record to its dataset, which mustn't hold a record with the
+// same primary key yet.
+func (s *Store) Insert(record types.Record) error {
+	primaryKey := record.PrimaryKey()
+	if _, ok := types.Schemas[primaryKey.Dataset]; !ok {
+		return fmt.Errorf("Unknown dataset %q", primaryKey.Dataset)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[primaryKey]; ok {
+		return fmt.Errorf("%w: %s", ErrExists, describeKey(primaryKey))
+	}
+
+	s.fileOne(record)
+	s.datasets[primaryKey.Dataset] = append(s.datasets[primaryKey.Dataset], record)
+
+	return nil
+}
+
+// Update replaces the record holding the same primary key, keeping its
+// place in the dataset. Index entries for values it no longer holds are
+// removed.
+func (s *Store) Update(record types.Record) error {
+	primaryKey := record.PrimaryKey()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[primaryKey]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, describeKey(primaryKey))
+	}
+
+	s.unfileOne(primaryKey)
+	s.fileOne(record)
+
+	records := s.datasets[primaryKey.Dataset]
+	updated := make([]types.Record, len(records))
+	for i, existing := range records {
+		if existing.PrimaryKey() == primaryKey {
+			updated[i] = record
+		} else {
+			updated[i] = existing
+		}
+	}
+	s.datasets[primaryKey.Dataset] = updated
+
+	return nil
+}
+
+// Delete removes the record of the dataset with the given _id, which must be
+// of the field's type, and returns it.
+func (s *Store) Delete(dataset string, id interface{}) (types.Record, error) {
+	primaryKey := types.Query{Dataset: dataset, Field: "_id", Value: id}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[primaryKey]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, describeKey(primaryKey))
+	}
+
+	s.unfileOne(primaryKey)
+
+	var deleted types.Record
+	var kept []types.Record
+	for _, record := range s.datasets[dataset] {
+		if record.PrimaryKey() == primaryKey {
+			deleted = record
+		} else {
+			kept = append(kept, record)
+		}
+	}
+	s.datasets[dataset] = kept
+
+	return deleted, nil
+}
+
+func describeKey(primaryKey types.Query) string {
+	return fmt.Sprintf("%s with %s %v", primaryKey.Dataset, primaryKey.Field, primaryKey.Value)
+}
+
+// file adds the record to the index under each of its keys, noting the
+// fields touched in changes.
+func (s *Store) file(record types.Record, changes map[fieldKey]bool) {
+	primaryKey := record.PrimaryKey()
+	keys := record.KeysForIndex()
+
+	s.keys[primaryKey] = append(s.keys[primaryKey], keys...)
+	for _, query := range keys {
+		s.index[query] = append(s.index[query], record)
+		s.count(query, 1, changes)
+	}
+}
+
+// unfile removes the records with the primary key from the index. Entries
+// are replaced rather than modified, as readers may still hold them.
+func (s *Store) unfile(primaryKey types.Query, changes map[fieldKey]bool) {
+	for _, query := range s.keys[primaryKey] {
+		s.count(query, -1, changes)
+
+		var kept []types.Record
+		for _, record := range s.index[query] {
+			if record.PrimaryKey() != primaryKey {
+				kept = append(kept, record)
+			}
+		}
+
+		if len(kept) > 0 {
+			s.index[query] = kept
+		} else {
+			delete(s.index, query)
+		}
+	}
+
+	delete(s.keys, primaryKey)
+}
+
+// fileOne files a record into a built store, adding the values it brings
+// to the fields' secondary structures.
+func (s *Store) fileOne(record types.Record) {
+	keys := record.KeysForIndex()
+
+	var appeared []types.Query
+	for _, query := range keys {
+		if s.values[fieldKey{query.Dataset, query.Field}][query.Value] == 0 && !containsQuery(appeared, query) {
+			appeared = append(appeared, query)
+		}
+	}
+
+	s.file(record, nil)
+
+	for _, query := range appeared {
+		s.addValue(query)
+	}
+	for _, query := range keys {
+		if text, ok := query.Value.(string); ok && IsFullText(query.Dataset, query.Field) {
+			s.textIndex(fieldKey{query.Dataset, query.Field}).add(record, text)
+		}
+	}
+}
+
+// unfileOne removes a record from a built store, dropping the values only
+// it held from the fields' secondary structures.
+func (s *Store) unfileOne(primaryKey types.Query) {
+	keys := s.keys[primaryKey]
+	s.unfile(primaryKey, nil)
+
+	var gone []types.Query
+	for _, query := range keys {
+		if _, ok := s.values[fieldKey{query.Dataset, query.Field}][query.Value]; !ok && !containsQuery(gone, query) {
+			gone = append(gone, query)
+		}
+	}
+
+	for _, query := range gone {
+		s.removeValue(query)
+	}
+	for _, query := range keys {
+		if text, ok := query.Value.(string); ok && IsFullText(query.Dataset, query.Field) {
+			s.textIndex(fieldKey{query.Dataset, query.Field}).remove(primaryKey, text)
+		}
+	}
+}
+
+func containsQuery(queries []types.Query, query types.Query) bool {
+	for _, other := range queries {
+		if other == query {
+			return true
+		}
+	}
+	return false
+}
+
+// addValue adds a value new to its field to the field's string and range
+// structures, as buildValues would have.
+func (s *Store) addValue(query types.Query) {
+	key := fieldKey{query.Dataset, query.Field}
+
+	if text, ok := query.Value.(string); ok {
+		if values, ok := s.strings[key]; ok {
+			values.add(text)
+		} else {
+			s.strings[key] = newStringValues([]string{text})
+		}
+	}
+
+	if !IsRangeField(key.Dataset, key.Field) {
+		return
+	}
+	if rangeKey, err := RangeKey(key.Dataset, key.Field, query.Value); err == nil {
+		if ordered, ok := s.ordered[key]; ok {
+			ordered.add(query.Value, rangeKey)
+		} else {
+			s.ordered[key] = newOrderedValues(map[interface{}]float64{query.Value: rangeKey})
+		}
+	}
+}
+
+// removeValue drops a value its field no longer holds from the field's
+// string and range structures.
+func (s *Store) removeValue(query types.Query) {
+	key := fieldKey{query.Dataset, query.Field}
+
+	if text, ok := query.Value.(string); ok {
+		if values, ok := s.strings[key]; ok {
+			values.remove(text)
+			if len(values.values) == 0 {
+				delete(s.strings, key)
+			}
+		}
+	}
+
+	if !IsRangeField(key.Dataset, key.Field) {
+		return
+	}
+	if rangeKey, err := RangeKey(key.Dataset, key.Field, query.Value); err == nil {
+		if ordered, ok := s.ordered[key]; ok {
+			ordered.remove(query.Value, rangeKey)
+		}
+	}
+}
+
+// textIndex returns the field's text index, starting one if the field's
+// dataset hasn't been loaded.
+func (s *Store) textIndex(key fieldKey) *textIndex {
+	text, ok := s.text[key]
+	if !ok {
+		text = newTextIndex()
+		s.text[key] = text
+	}
+	return text
+}
+
+// count adjusts the number of entries of the query's value. The field is
+// recorded in changes, unless nil, as true when the value appeared or
+// disappeared.
+func (s *Store) count(query types.Query, delta int, changes map[fieldKey]bool) {
+	key := fieldKey{Dataset: query.Dataset, Field: query.Field}
+
+	counts, ok := s.values[key]
+	if !ok {
+		counts = map[interface{}]int{}
+		s.values[key] = counts
+	}
+
+	before := counts[query.Value]
+	after := before + delta
+	if after > 0 {
+		counts[query.Value] = after
+	} else {
+		delete(counts, query.Value)
+	}
+
+	if changes != nil {
+		changes[key] = changes[key] || before == 0 || after <= 0
+	}
+}
+
+// refresh rebuilds the secondary structures of the changed fields. Text
+// fields are rebuilt whenever touched, since a record may have been added
+// under a value that was already indexed.
+func (s *Store) refresh(changes map[fieldKey]bool) {
+	for key, valuesChanged := range changes {
+		s.setField(key, s.buildField(key, valuesChanged))
+	}
+}
+
+// fieldStructures are the secondary structures of one field. A nil entry
+// is left as it was, or removed when the field's values changed.
+type fieldStructures struct {
+	valuesChanged bool
+	strings       *stringValues
+	ordered       *orderedValues
+	text          *textIndex
+}
+
+// buildField builds the structures of the field without changing the
+// store, so fields can be built side by side.
+func (s *Store) buildField(key fieldKey, valuesChanged bool) fieldStructures {
+	built := fieldStructures{valuesChanged: valuesChanged}
+
+	strings := s.strings[key]
+	if valuesChanged {
+		built.strings, built.ordered = s.buildValues(key)
+		strings = built.strings
+	}
+	if types.FieldTypeOf(key.Dataset, key.Field) == types.TextField {
+		built.text = s.buildTextIndex(key, strings)
+	}
+
+	return built
+}
+
+func (s *Store) setField(key fieldKey, built fieldStructures) {
+	if built.valuesChanged {
+		if built.strings != nil {
+			s.strings[key] = built.strings
+		} else {
+			delete(s.strings, key)
+		}
+		if built.ordered != nil {
+			s.ordered[key] = built.ordered
+		}
+	}
+	if built.text != nil {
+		s.text[key] = built.text
+	}
+}
+
+// buildValues sorts the distinct values of the field for the string and
+// range lookups. Fields without string values get no string structure and
+// fields that aren't range fields no ordered one.
+func (s *Store) buildValues(key fieldKey) (*stringValues, *orderedValues) {
+	var values []string
+	var rangeKeys map[interface{}]float64
+	if IsRangeField(key.Dataset, key.Field) {
+		rangeKeys = map[interface{}]float64{}
+	}
+
+	for value := range s.values[key] {
+		if text, ok := value.(string); ok {
+			values = append(values, text)
+		}
+
+		// Values that aren't numbers or timestamps, like @missing, just
+		// can't be found by range
+		if rangeKeys != nil {
+			if rangeKey, err := RangeKey(key.Dataset, key.Field, value); err == nil {
+				rangeKeys[value] = rangeKey
+			}
+		}
+	}
+
+	var strings *stringValues
+	if len(values) > 0 {
+		strings = newStringValues(values)
+	}
+
+	var ordered *orderedValues
+	if rangeKeys != nil {
+		ordered = newOrderedValues(rangeKeys)
+	}
+
+	return strings, ordered
+}
+
+// buildTextIndex walks the field's values in sorted order so documents get
+// the same numbering, and therefore the same tie-breaks, on every run.
+func (s *Store) buildTextIndex(key fieldKey, values *stringValues) *textIndex {
+	text := newTextIndex()
+
+	if values != nil {
+		for _, value := range values.values {
+			for _, record := range s.index[types.Query{Dataset: key.Dataset, Field: key.Field, Value: value}] {
+				text.add(record, value)
+			}
+		}
+	}
+
+	return text
+}
+
+// Lookup returns the records filed under the query.
+func (s *Store) Lookup(query types.Query) []types.Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index[query]
+}
+
+// Records returns every record of the dataset, in load order.
+func (s *Store) Records(dataset string) []types.Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.datasets[dataset]
+}
+
+// EqualFold returns the indexed values of the field equal to value, ignoring case.
+func (s *Store) EqualFold(dataset string, field string, value string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if values, ok := s.strings[fieldKey{dataset, field}]; ok {
+		return values.equalFold(value)
+	}
+	return nil
+}
+
+// WithPrefix returns the indexed values of the field starting with prefix, ignoring case.
+func (s *Store) WithPrefix(dataset string, field string, prefix string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if values, ok := s.strings[fieldKey{dataset, field}]; ok {
+		return values.withPrefix(prefix)
+	}
+	return nil
+}
+
+// Containing returns the indexed values of the field containing substring, ignoring case.
+func (s *Store) Containing(dataset string, field string, substring string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if values, ok := s.strings[fieldKey{dataset, field}]; ok {
+		return values.containing(substring)
+	}
+	return nil
+}
+
+// Matching returns the indexed values of the field matched by re.
+func (s *Store) Matching(dataset string, field string, re *regexp.Regexp) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if values, ok := s.strings[fieldKey{dataset, field}]; ok {
+		return values.matching(re)
+	}
+	return nil
+}
+
+// Range returns the indexed values of the field within the bounds, in
+// ascending order. A nil bound leaves that end of the range open.
+func (s *Store) Range(dataset string, field string, lower *Bound, upper *Bound) []interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if values, ok := s.ordered[fieldKey{dataset, field}]; ok {
+		return values.between(lower, upper)
+	}
+	return nil
+}
+
+// SearchText runs a full text query against the field and returns the
+// matching records ranked by BM25 score.
+func (s *Store) SearchText(dataset string, field string, query string) ([]types.Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	text, ok := s.text[fieldKey{dataset, field}]
+	if !ok {
+		return nil, fmt.Errorf("%s %s isn't a full text field", dataset, field)
+	}
+	return text.search(query), nil
+}
@@ -0,0 +1,370 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/config"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+func TestStoreChanges(t *testing.T) {
+	store := NewStore(sampleRecords(t))
+
+	user := store.Lookup(types.Query{Dataset: "users", Field: "_id", Value: 1})[0].(types.User)
+	old := user
+	alias := "Zeb"
+	user.Name = "Zebulon Quartz"
+	user.Alias = &alias
+	user.Tags = []string{"Quartzville"}
+	user.CreatedAt = "2030-01-01T00:00:00 -10:00"
+	if err := store.Update(user); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Delete("users", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Insert(types.User{Id: 1000, Name: "Ada Quartz", Tags: []string{"Quartzville"}, CreatedAt: "2030-01-02T00:00:00 -10:00"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tickets := store.Records("tickets")
+	ticket := tickets[0].(types.Ticket)
+	oldTicket := ticket
+	ticket.Subject = "A quasar over Zanzibar"
+	ticket.Description = nil
+	if err := store.Update(ticket); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Delete("tickets", tickets[1].(types.Ticket).Id); err != nil {
+		t.Fatal(err)
+	}
+	added := tickets[2].(types.Ticket)
+	added.Id, added.ExternalId, added.Subject = "new-ticket", "new-external-id", "Another quasar"
+	if err := store.Insert(added); err != nil {
+		t.Fatal(err)
+	}
+
+	want := NewStore(map[string][]types.Record{
+		"users":         store.Records("users"),
+		"organizations": store.Records("organizations"),
+		"tickets":       store.Records("tickets"),
+	})
+	sameEntries(t, want, store)
+	sameSecondary(t, want, store)
+
+	// Nothing is found under what the records held before
+	if found := store.Lookup(types.Query{Dataset: "users", Field: "name", Value: old.Name}); len(found) != 0 {
+		t.Errorf("users name %q still finds %d records", old.Name, len(found))
+	}
+	if found := store.EqualFold("users", "name", old.Name); len(found) != 0 {
+		t.Errorf("users name %q is still an indexed value", old.Name)
+	}
+	if found := store.Containing("users", "name", old.Name); len(found) != 0 {
+		t.Errorf("users name still has values containing %q", old.Name)
+	}
+	found, err := store.SearchText("tickets", "subject", oldTicket.Subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range found {
+		if record.PrimaryKey() == ticket.PrimaryKey() {
+			t.Errorf("ticket %s is still found by its old subject", ticket.Id)
+		}
+	}
+}
+
+// TestStoreInsertIntoEmpty ranks records inserted into datasets with no
+// records yet as if they had been loaded.
+func TestStoreInsertIntoEmpty(t *testing.T) {
+	store := NewStore(map[string][]types.Record{})
+
+	for _, record := range []types.Record{
+		types.Ticket{Id: "a", Subject: "A quasar"},
+		types.Ticket{Id: "b", Subject: "A quasar seen from a lighthouse near Zanzibar"},
+		types.Ticket{Id: "c", Subject: "Quasar quasar problem"},
+		types.Organization{Id: 1, Details: "Quasar Problem Solving"},
+		types.Organization{Id: 2, Details: "Artisan"},
+	} {
+		if err := store.Insert(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := NewStore(map[string][]types.Record{
+		"tickets":       store.Records("tickets"),
+		"organizations": store.Records("organizations"),
+	})
+	sameEntries(t, want, store)
+	sameSecondary(t, want, store)
+}
+
+// TestTextIndexCompacts updates a ticket until the documents it left behind
+// have been reclaimed more than once.
+func TestTextIndexCompacts(t *testing.T) {
+	store := NewStore(sampleRecords(t))
+	ticket := store.Records("tickets")[0].(types.Ticket)
+
+	for i := 0; i < 1000; i++ {
+		ticket.Subject = fmt.Sprintf("A quasar problem numbered %d", i)
+		if err := store.Update(ticket); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	text := store.text[fieldKey{"tickets", "subject"}]
+	if len(text.docs) > 2*text.live+compactSlack {
+		t.Errorf("%d documents are kept for %d records", len(text.docs), text.live)
+	}
+
+	want := NewStore(map[string][]types.Record{
+		"users":         store.Records("users"),
+		"organizations": store.Records("organizations"),
+		"tickets":       store.Records("tickets"),
+	})
+	sameSecondary(t, want, store)
+}
+
+// TestStoreReadsDuringUpdates is meant for -race: the slices the reads
+// return must not be written by the updates that follow.
+func TestStoreReadsDuringUpdates(t *testing.T) {
+	store := NewStore(sampleRecords(t))
+	primaryKey := types.Query{Dataset: "users", Field: "_id", Value: 1}
+	user := store.Lookup(primaryKey)[0].(types.User)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				if found := store.Lookup(primaryKey); len(found) != 1 {
+					t.Errorf("users _id 1 found %d records during updates", len(found))
+				}
+				var values []string
+				values = append(values, store.WithPrefix("users", "name", "")...)
+				values = append(values, store.Containing("users", "name", "quartz")...)
+				values = append(values, store.EqualFold("users", "tags", "quartzville")...)
+				for _, value := range values {
+					store.Lookup(types.Query{Dataset: "users", Field: "name", Value: value})
+				}
+				for range store.Range("users", "created_at", nil, nil) {
+				}
+				if _, err := store.SearchText("tickets", "subject", "quasar"); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+
+	ticket := store.Records("tickets")[0].(types.Ticket)
+	for i := 0; i < 200; i++ {
+		user.Name = fmt.Sprintf("Quartz %d", i)
+		user.Tags = []string{"Quartzville", fmt.Sprintf("Quartz %d", i%7)}
+		user.CreatedAt = fmt.Sprintf("2030-01-%02dT00:00:00 -10:00", i%28+1)
+		if err := store.Update(user); err != nil {
+			t.Fatal(err)
+		}
+
+		ticket.Subject = fmt.Sprintf("A quasar numbered %d", i)
+		if err := store.Update(ticket); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.Insert(types.User{Id: 1000 + i, Name: fmt.Sprintf("Passing Quartz %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Delete("users", 1000+i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+// sameEntries compares the records of each dataset and the records filed
+// under every value, ignoring their order: an updated record is filed again
+// after the others holding the same value.
+func sameEntries(t *testing.T, want *Store, got *Store) {
+	t.Helper()
+
+	for _, dataset := range types.Datasets {
+		if !reflect.DeepEqual(want.Records(dataset), got.Records(dataset)) {
+			t.Errorf("%s records differ", dataset)
+		}
+	}
+
+	want.mu.RLock()
+	defer want.mu.RUnlock()
+	got.mu.RLock()
+	defer got.mu.RUnlock()
+
+	if len(want.index) != len(got.index) {
+		t.Errorf("%d values are indexed, expected %d", len(got.index), len(want.index))
+	}
+	for query, records := range want.index {
+		if !reflect.DeepEqual(primaryKeys(records), primaryKeys(got.index[query])) {
+			t.Errorf("%s %s %v finds different records", query.Dataset, query.Field, query.Value)
+		}
+	}
+	if !reflect.DeepEqual(want.values, got.values) {
+		t.Error("values are counted differently")
+	}
+}
+
+// sameSecondary compares what the string, range and full text lookups of
+// each searchable field find. Values the range lookup holds under the same
+// key and records with the same score may come in either order.
+func sameSecondary(t *testing.T, want *Store, got *Store) {
+	t.Helper()
+
+	everything := regexp.MustCompile("")
+	for _, dataset := range types.Datasets {
+		for _, field := range types.Schemas[dataset].SearchableFields() {
+			for _, c := range []struct {
+				lookup string
+				want   []string
+				got    []string
+			}{
+				{"prefix", want.WithPrefix(dataset, field, ""), got.WithPrefix(dataset, field, "")},
+				{"regex", want.Matching(dataset, field, everything), got.Matching(dataset, field, everything)},
+				{"contains", want.Containing(dataset, field, "qua"), got.Containing(dataset, field, "qua")},
+				{"contains", want.Containing(dataset, field, "a"), got.Containing(dataset, field, "a")},
+				{"iexact", want.EqualFold(dataset, field, "quartzville"), got.EqualFold(dataset, field, "quartzville")},
+			} {
+				if !reflect.DeepEqual(c.want, c.got) {
+					t.Errorf("%s %s %s lookup finds %d values, expected %d", dataset, field, c.lookup, len(c.got), len(c.want))
+				}
+			}
+
+			if !reflect.DeepEqual(sortedValues(want.Range(dataset, field, nil, nil)), sortedValues(got.Range(dataset, field, nil, nil))) {
+				t.Errorf("%s %s range lookup finds different values", dataset, field)
+			}
+
+			if !IsFullText(dataset, field) {
+				continue
+			}
+			for _, query := range []string{"quasar", "zanzibar", "problem", "a"} {
+				sameRanking(t, rankedText(t, want, dataset, field, query), rankedText(t, got, dataset, field, query), dataset+" "+field+" search for "+query)
+			}
+		}
+	}
+}
+
+type rankedRecord struct {
+	key   types.Query
+	score float64
+}
+
+// rankedText runs a full text search and notes the score of each record
+// found, in the order found.
+func rankedText(t *testing.T, s *Store, dataset string, field string, query string) []rankedRecord {
+	t.Helper()
+
+	found, err := s.SearchText(dataset, field, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	text := s.text[fieldKey{dataset, field}]
+	scores := map[types.Query]float64{}
+	for doc, score := range text.score(query) {
+		scores[text.docs[doc].PrimaryKey()] = score
+	}
+
+	ranked := make([]rankedRecord, len(found))
+	for i, record := range found {
+		ranked[i] = rankedRecord{key: record.PrimaryKey(), score: scores[record.PrimaryKey()]}
+	}
+	return ranked
+}
+
+// sameRanking compares two rankings, which may only order records with the
+// same score differently.
+func sameRanking(t *testing.T, want []rankedRecord, got []rankedRecord, search string) {
+	t.Helper()
+
+	if len(want) != len(got) {
+		t.Errorf("%s finds %d records, expected %d", search, len(got), len(want))
+		return
+	}
+	for i := range want {
+		if !sameScore(want[i].score, got[i].score) {
+			t.Errorf("%s ranks %v at %d with score %v, expected %v with score %v", search, got[i].key.Value, i, got[i].score, want[i].key.Value, want[i].score)
+			return
+		}
+	}
+
+	for from := 0; from < len(want); {
+		to := from + 1
+		for to < len(want) && sameScore(want[from].score, want[to].score) {
+			to++
+		}
+		wantTied, gotTied := map[types.Query]bool{}, map[types.Query]bool{}
+		for i := from; i < to; i++ {
+			wantTied[want[i].key] = true
+			gotTied[got[i].key] = true
+		}
+		if !reflect.DeepEqual(wantTied, gotTied) {
+			t.Errorf("%s ranks different records at %d to %d", search, from, to-1)
+		}
+		from = to
+	}
+}
+
+// sameScore allows for the rounding of scores summed in another order. NaN
+// is no score at all.
+func sameScore(a float64, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(a))
+}
+
+func sortedValues(values []interface{}) []string {
+	var sorted []string
+	for _, value := range values {
+		sorted = append(sorted, fmt.Sprint(value))
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func primaryKeys(records []types.Record) map[types.Query]bool {
+	keys := map[types.Query]bool{}
+	for _, record := range records {
+		keys[record.PrimaryKey()] = true
+	}
+	return keys
+}
+
+// sampleRecords loads the records of the sample data.
+func sampleRecords(t *testing.T) map[string][]types.Record {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.DataDir = filepath.Join("..", "..", "data")
+	store, err := LoadAndIndexData(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	datasets := map[string][]types.Record{}
+	for _, dataset := range types.Datasets {
+		datasets[dataset] = store.Records(dataset)
+	}
+	return datasets
+}
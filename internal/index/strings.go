@@ -0,0 +1,282 @@
+package index
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+const gramSize = 3
+
+// IsStringField reports whether the field holds strings, the only values
+// the case-insensitive, prefix, substring and regex lookups compare.
+func IsStringField(dataset string, field string) bool {
+	switch types.FieldTypeOf(dataset, field) {
+	case types.StringField, types.TextField:
+		return true
+	}
+	return false
+}
+
+type fieldKey struct {
+	Dataset string
+	Field   string
+}
+
+// stringValues holds the distinct string values indexed under one field,
+// sorted case-insensitively so equality and prefix lookups are binary
+// searches, plus a trigram index so substring lookups only verify candidates
+// instead of scanning every value.
+//
+// Values are added and removed one at a time as records change. The sorted
+// slices are replaced rather than modified, as lookups hand out parts of
+// them, which costs a copy of the field's values but no sorting. The
+// trigram index refers to values by id, the position in byId, so it only
+// grows; ids is built on the first removal to tell the ids still in use.
+type stringValues struct {
+	values    []string
+	lower     []string
+	byId      []string
+	lowerById []string
+	ids       map[string]int
+	grams     map[string][]int
+}
+
+func newStringValues(values []string) *stringValues {
+	sort.Slice(values, func(i, j int) bool {
+		return lessValue(values[i], strings.ToLower(values[i]), values[j], strings.ToLower(values[j]))
+	})
+
+	s := &stringValues{
+		values: values,
+		lower:  make([]string, len(values)),
+		byId:   make([]string, len(values)),
+		grams:  map[string][]int{},
+	}
+
+	for i, value := range values {
+		s.lower[i] = strings.ToLower(value)
+		s.byId[i] = value
+		s.addGrams(i, s.lower[i])
+	}
+	s.lowerById = append([]string(nil), s.lower...)
+
+	return s
+}
+
+// lessValue orders values case-insensitively, then by their exact text.
+func lessValue(a string, aLower string, b string, bLower string) bool {
+	if aLower != bLower {
+		return aLower < bLower
+	}
+	return a < b
+}
+
+func (s *stringValues) addGrams(id int, lower string) {
+	seen := map[string]bool{}
+	for _, gram := range grams(lower) {
+		if !seen[gram] {
+			seen[gram] = true
+			s.grams[gram] = append(s.grams[gram], id)
+		}
+	}
+}
+
+// add inserts a value the field didn't hold yet.
+func (s *stringValues) add(value string) {
+	lower := strings.ToLower(value)
+	at := sort.Search(len(s.values), func(i int) bool {
+		return !lessValue(s.values[i], s.lower[i], value, lower)
+	})
+
+	s.values = insertString(s.values, at, value)
+	s.lower = insertString(s.lower, at, lower)
+
+	id := len(s.byId)
+	s.byId = append(s.byId, value)
+	s.lowerById = append(s.lowerById, lower)
+	if s.ids != nil {
+		s.ids[value] = id
+	}
+	s.addGrams(id, lower)
+}
+
+// remove drops a value the field no longer holds. Once most ids are out of
+// use the trigram index is built again from the values left.
+func (s *stringValues) remove(value string) {
+	lower := strings.ToLower(value)
+	at := sort.Search(len(s.values), func(i int) bool {
+		return !lessValue(s.values[i], s.lower[i], value, lower)
+	})
+	if at == len(s.values) || s.values[at] != value {
+		return
+	}
+
+	s.values = removeString(s.values, at)
+	s.lower = removeString(s.lower, at)
+
+	if len(s.byId) > 2*len(s.values)+compactSlack {
+		*s = *newStringValues(append([]string(nil), s.values...))
+		return
+	}
+	if s.ids == nil {
+		s.ids = make(map[string]int, len(s.byId))
+		for id, other := range s.byId {
+			s.ids[other] = id
+		}
+	}
+	delete(s.ids, value)
+}
+
+// compactSlack keeps small fields from rebuilding their trigram or text
+// index on every other removal.
+const compactSlack = 64
+
+// inUse reports whether the id still stands for a value of the field.
+func (s *stringValues) inUse(id int) bool {
+	if s.ids == nil {
+		return true
+	}
+	current, ok := s.ids[s.byId[id]]
+	return ok && current == id
+}
+
+func insertString(values []string, at int, value string) []string {
+	inserted := make([]string, len(values)+1)
+	copy(inserted, values[:at])
+	inserted[at] = value
+	copy(inserted[at+1:], values[at:])
+	return inserted
+}
+
+func removeString(values []string, at int) []string {
+	removed := make([]string, len(values)-1)
+	copy(removed, values[:at])
+	copy(removed[at:], values[at+1:])
+	return removed
+}
+
+func (s *stringValues) equalFold(value string) []string {
+	lower := strings.ToLower(value)
+	from := sort.SearchStrings(s.lower, lower)
+	to := from
+	for to < len(s.lower) && s.lower[to] == lower {
+		to++
+	}
+	return s.values[from:to]
+}
+
+func (s *stringValues) withPrefix(prefix string) []string {
+	lower := strings.ToLower(prefix)
+	from := sort.SearchStrings(s.lower, lower)
+	to := from
+	for to < len(s.lower) && strings.HasPrefix(s.lower[to], lower) {
+		to++
+	}
+	return s.values[from:to]
+}
+
+// containing returns the values holding substring, in sorted order.
+// Needles shorter than a trigram can't be narrowed down, so every value is
+// checked.
+func (s *stringValues) containing(substring string) []string {
+	lower := strings.ToLower(substring)
+
+	var matches []string
+	if len(grams(lower)) == 0 {
+		for i, value := range s.values {
+			if strings.Contains(s.lower[i], lower) {
+				matches = append(matches, value)
+			}
+		}
+		return matches
+	}
+
+	var matchesLower []string
+	for _, id := range s.candidates(lower) {
+		value, valueLower := s.byId[id], s.lowerById[id]
+		if s.inUse(id) && strings.Contains(valueLower, lower) {
+			matches = append(matches, value)
+			matchesLower = append(matchesLower, valueLower)
+		}
+	}
+
+	// Ids follow the order values were added in
+	sort.Sort(byValue{matches, matchesLower})
+	return matches
+}
+
+type byValue struct {
+	values []string
+	lower  []string
+}
+
+func (b byValue) Len() int { return len(b.values) }
+func (b byValue) Less(i, j int) bool {
+	return lessValue(b.values[i], b.lower[i], b.values[j], b.lower[j])
+}
+func (b byValue) Swap(i, j int) {
+	b.values[i], b.values[j] = b.values[j], b.values[i]
+	b.lower[i], b.lower[j] = b.lower[j], b.lower[i]
+}
+
+// candidates returns the ids of the values holding every trigram of the
+// needle, which must have at least one.
+func (s *stringValues) candidates(needle string) []int {
+	needleGrams := grams(needle)
+
+	sort.Slice(needleGrams, func(i, j int) bool {
+		return len(s.grams[needleGrams[i]]) < len(s.grams[needleGrams[j]])
+	})
+
+	candidates := s.grams[needleGrams[0]]
+	for _, gram := range needleGrams[1:] {
+		if len(candidates) == 0 {
+			break
+		}
+		candidates = intersect(candidates, s.grams[gram])
+	}
+	return candidates
+}
+
+func (s *stringValues) matching(re *regexp.Regexp) []string {
+	var matches []string
+	for _, value := range s.values {
+		if re.MatchString(value) {
+			matches = append(matches, value)
+		}
+	}
+	return matches
+}
+
+func grams(value string) []string {
+	if len(value) < gramSize {
+		return nil
+	}
+
+	result := make([]string, 0, len(value)-gramSize+1)
+	for i := 0; i+gramSize <= len(value); i++ {
+		result = append(result, value[i:i+gramSize])
+	}
+	return result
+}
+
+// intersect returns the values present in both ascending slices.
+func intersect(a []int, b []int) []int {
+	var result []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
@@ -0,0 +1,120 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// JSON writes the results as an indented JSON array of objects, or with
+// Lines as one compact object per line. Each object holds the record's
+// fields, leaving out missing ones, followed by its related records up to
+// Depth relationships away, under their relation names: an object for a
+// record it refers to, such as "submitter", and an array for records
+// referring to it, such as "submitted_tickets".
+type JSON struct {
+	Lines bool
+	Depth int
+}
+
+func (j JSON) Render(w io.Writer, lookup types.Lookup, results []types.Record) error {
+	objects := make([]json.RawMessage, len(results))
+	for i, result := range results {
+		object, err := recordJSON(lookup, result, j.Depth, nil)
+		if err != nil {
+			return err
+		}
+		objects[i] = object
+	}
+
+	if j.Lines {
+		for _, object := range objects {
+			if _, err := fmt.Fprintf(w, "%s\n", object); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	body, err := json.MarshalIndent(objects, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", body)
+	return err
+}
+
+// JSONObject encodes a single record the way JSON writes each result.
+func JSONObject(lookup types.Lookup, record types.Record, depth int) (json.RawMessage, error) {
+	return recordJSON(lookup, record, depth, nil)
+}
+
+// recordJSON encodes the record's displayable fields in schema order, which a
+// map would lose.
+func recordJSON(lookup types.Lookup, record types.Record, depth int, path []types.Query) (json.RawMessage, error) {
+	schema, err := schemaOf(record)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+
+	writeMember := func(name string, value interface{}) error {
+		if buffer.Len() > 1 {
+			buffer.WriteByte(',')
+		}
+
+		key, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("encoding %s %s: %w", schema.Dataset, name, err)
+		}
+
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(encoded)
+		return nil
+	}
+
+	for _, field := range schema.Fields {
+		if !field.Displayable {
+			continue
+		}
+		value := schema.Value(record, field)
+		if value == nil {
+			continue
+		}
+		if err := writeMember(field.Name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	relations, path := expand(lookup, record, depth, path)
+	for _, relation := range relations {
+		objects := make([]json.RawMessage, len(relation.Records))
+		for i, related := range relation.Records {
+			if objects[i], err = recordJSON(lookup, related, depth-1, path); err != nil {
+				return nil, err
+			}
+		}
+
+		var value interface{} = objects
+		if !relation.Many {
+			value = objects[0]
+		}
+		if err := writeMember(relation.Name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,161 @@
+// Package output writes search results in the formats users can pick.
+// Records only hold data; every layout is derived from the dataset schemas.
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+	"github.com/zendesk/melbourne_code_club_go/internal/util"
+)
+
+// Renderer writes search results. The lookup finds the records the results
+// refer to.
+type Renderer interface {
+	Render(w io.Writer, lookup types.Lookup, results []types.Record) error
+}
+
+// Format names a Renderer.
+type Format string
+
+const (
+	TextFormat Format = "text"
+	// JSONFormat writes the results as one indented JSON array.
+	JSONFormat Format = "json"
+	// NDJSONFormat writes one compact JSON object per line.
+	NDJSONFormat Format = "ndjson"
+	CSVFormat    Format = "csv"
+	TSVFormat    Format = "tsv"
+	// TableFormat lines the columns up for reading in a terminal.
+	TableFormat    Format = "table"
+	MarkdownFormat Format = "markdown"
+)
+
+// Formats lists the output formats in the order they are offered to users.
+var Formats []Format = []Format{TextFormat, JSONFormat, NDJSONFormat, CSVFormat, TSVFormat, TableFormat, MarkdownFormat}
+
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == name {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown output format %q, expected one of %v", name, Formats)
+}
+
+// IsTabular reports whether the format writes a row per record, so its
+// columns can be picked.
+func IsTabular(format Format) bool {
+	switch format {
+	case CSVFormat, TSVFormat, TableFormat, MarkdownFormat:
+		return true
+	}
+	return false
+}
+
+// New returns the renderer for results of the dataset, checking the format
+// and the comma separated column list typed by a user. Without columns, the
+// tabular formats write every searchable field. depth is how many
+// relationships away related records are written by the text and JSON
+// formats.
+func New(dataset string, format string, columns string, depth int) (Renderer, error) {
+	fields, ok := types.DataTypes[dataset]
+	if !ok {
+		return nil, fmt.Errorf("Unknown dataset %q", dataset)
+	}
+
+	outputFormat, err := ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	if columns != "" && !IsTabular(outputFormat) {
+		return nil, fmt.Errorf("Columns can only be picked for %s, %s, %s and %s output", CSVFormat, TSVFormat, TableFormat, MarkdownFormat)
+	}
+
+	if depth < 0 {
+		return nil, fmt.Errorf("Invalid depth %d, expected 0 or more", depth)
+	}
+
+	picked := fields
+	if columns != "" {
+		picked = nil
+		for _, column := range strings.Split(columns, ",") {
+			column = strings.TrimSpace(column)
+			if !util.ContainsString(fields, column) {
+				return nil, fmt.Errorf("Unknown column %q for %s, expected some of %v", column, dataset, fields)
+			}
+			picked = append(picked, column)
+		}
+	}
+
+	columnsOf := Columns{Dataset: dataset, Names: picked}
+
+	switch outputFormat {
+	case JSONFormat:
+		return JSON{Depth: depth}, nil
+	case NDJSONFormat:
+		return JSON{Lines: true, Depth: depth}, nil
+	case CSVFormat:
+		return Delimited{Columns: columnsOf, Comma: ','}, nil
+	case TSVFormat:
+		return Delimited{Columns: columnsOf, Comma: '\t'}, nil
+	case TableFormat:
+		return Table{Columns: columnsOf}, nil
+	case MarkdownFormat:
+		return Markdown{Columns: columnsOf}, nil
+	default:
+		return Text{Depth: depth}, nil
+	}
+}
+
+// DefaultDepth expands the records directly related to each result.
+const DefaultDepth = 1
+
+// expand returns the relations of the record to write when depth more
+// relationships may be followed. Records already on the path from the
+// result are left out, as they would only be repeated: the organization of
+// one of an organization's users, say. The returned path includes the record.
+func expand(lookup types.Lookup, record types.Record, depth int, path []types.Query) ([]types.Relation, []types.Query) {
+	if depth <= 0 {
+		return nil, path
+	}
+
+	path = append(path[:len(path):len(path)], record.PrimaryKey())
+
+	var relations []types.Relation
+	for _, relation := range record.Related(lookup) {
+		var records []types.Record
+		for _, related := range relation.Records {
+			if !containsKey(path, related.PrimaryKey()) {
+				records = append(records, related)
+			}
+		}
+		if len(records) > 0 {
+			relation.Records = records
+			relations = append(relations, relation)
+		}
+	}
+
+	return relations, path
+}
+
+func containsKey(keys []types.Query, key types.Query) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func schemaOf(record types.Record) (*types.Schema, error) {
+	dataset := record.PrimaryKey().Dataset
+	schema, ok := types.Schemas[dataset]
+	if !ok {
+		return nil, fmt.Errorf("No schema for %s", dataset)
+	}
+	return schema, nil
+}
@@ -0,0 +1,133 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/juju/ansiterm/tabwriter"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// Columns are the fields of the dataset written by a tabular renderer, in
+// order.
+type Columns struct {
+	Dataset string
+	Names   []string
+}
+
+// Delimited writes a header row of column names and a row per record, with
+// Comma separating the fields: CSV or TSV.
+type Delimited struct {
+	Columns
+	Comma rune
+}
+
+func (d Delimited) Render(w io.Writer, lookup types.Lookup, results []types.Record) error {
+	rows, err := d.rows(results)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = d.Comma
+	return writer.WriteAll(rows)
+}
+
+// Table writes the rows of Delimited as aligned columns.
+type Table struct {
+	Columns
+}
+
+func (t Table) Render(w io.Writer, lookup types.Lookup, results []types.Record) error {
+	rows, err := t.rows(results)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		// Tabs and line breaks inside a value would break the alignment
+		for i, cell := range row {
+			row[i] = strings.Join(strings.Fields(cell), " ")
+		}
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+	return writer.Flush()
+}
+
+// Markdown writes the rows of Delimited as a Markdown table.
+type Markdown struct {
+	Columns
+}
+
+func (m Markdown) Render(w io.Writer, lookup types.Lookup, results []types.Record) error {
+	rows, err := m.rows(results)
+	if err != nil {
+		return err
+	}
+
+	escaper := strings.NewReplacer("|", `\|`)
+	for i, row := range rows {
+		for j, cell := range row {
+			row[j] = escaper.Replace(strings.Join(strings.Fields(cell), " "))
+		}
+		if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | ")); err != nil {
+			return err
+		}
+
+		if i == 0 {
+			rule := make([]string, len(row))
+			for j := range rule {
+				rule[j] = "---"
+			}
+			if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(rule, " | ")); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// rows returns the header row followed by a row per record.
+func (c Columns) rows(results []types.Record) ([][]string, error) {
+	schema, ok := types.Schemas[c.Dataset]
+	if !ok {
+		return nil, fmt.Errorf("No schema for %s", c.Dataset)
+	}
+
+	fields := make([]types.Field, len(c.Names))
+	for i, name := range c.Names {
+		field, ok := schema.Field(name)
+		if !ok {
+			return nil, fmt.Errorf("Unknown column %q for %s", name, c.Dataset)
+		}
+		fields[i] = field
+	}
+
+	rows := [][]string{append([]string(nil), c.Names...)}
+	for _, result := range results {
+		row := make([]string, len(fields))
+		for i, field := range fields {
+			row[i] = cell(schema.Value(result, field))
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// cell writes a field value for a table: missing values are left blank and
+// list elements are separated by commas.
+func cell(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(value, ", ")
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,101 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// Text writes each result as a block of aligned `name: value` lines followed
+// by a block per related record, up to Depth relationships away, and ends
+// with the number of results.
+type Text struct {
+	Depth int
+}
+
+func (t Text) Render(w io.Writer, lookup types.Lookup, results []types.Record) error {
+	for _, result := range results {
+		schema, err := schemaOf(result)
+		if err != nil {
+			return err
+		}
+
+		if err := writeText(w, lookup, result, recordName(schema.Dataset), 2, t.Depth, nil); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+
+	_, err := fmt.Fprintln(w, "Number of results ", len(results))
+	return err
+}
+
+// writeText writes the record under a Markdown style heading of the level,
+// then its related records a level deeper.
+func writeText(w io.Writer, lookup types.Lookup, record types.Record, heading string, level int, depth int, path []types.Query) error {
+	schema, err := schemaOf(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%s %s.\n%s\n", strings.Repeat("#", level), heading, describe(schema, record)); err != nil {
+		return err
+	}
+
+	relations, path := expand(lookup, record, depth, path)
+	for _, relation := range relations {
+		for i, related := range relation.Records {
+			heading := title(relation.Name)
+			if relation.Many {
+				heading = fmt.Sprintf("%s (%d of %d)", heading, i+1, len(relation.Records))
+			}
+			if err := writeText(w, lookup, related, heading, level+1, depth-1, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// describe lays out the record's displayable fields one per line, names
+// aligned on the colon.
+func describe(schema *types.Schema, record types.Record) string {
+	width := 0
+	for _, field := range schema.Fields {
+		if field.Displayable && len(field.Name) > width {
+			width = len(field.Name)
+		}
+	}
+
+	var lines []string
+	for _, field := range schema.Fields {
+		if !field.Displayable {
+			continue
+		}
+		if value := schema.Value(record, field); value != nil {
+			lines = append(lines, fmt.Sprintf("%*s: %v", width, field.Name, value))
+		} else {
+			lines = append(lines, fmt.Sprintf("%*s:", width, field.Name))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// recordName turns a dataset name into the name of one of its records, as
+// in "tickets" to "Ticket".
+func recordName(dataset string) string {
+	return title(strings.TrimSuffix(dataset, "s"))
+}
+
+// title turns a relation name such as "submitted_tickets" into a heading.
+func title(name string) string {
+	if name == "" {
+		return name
+	}
+	name = strings.Replace(name, "_", " ", -1)
+	return strings.ToUpper(name[:1]) + name[1:]
+}
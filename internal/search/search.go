@@ -2,19 +2,258 @@ package search
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
+	"github.com/zendesk/melbourne_code_club_go/internal/index"
 	"github.com/zendesk/melbourne_code_club_go/internal/types"
 )
 
-func SearchData(index types.Index, query types.Query) string {
-	results := index[query]
+// Mode says how the query value is compared with the indexed values.
+type Mode string
 
-	var resultSum string
-	for _, result := range results {
-		resultSum = resultSum + result.Print(index) + "\n"
+const (
+	Exact    Mode = "exact"
+	IExact   Mode = "iexact"
+	Prefix   Mode = "prefix"
+	Contains Mode = "contains"
+	Regex    Mode = "regex"
+	Text     Mode = "text"
+	Greater  Mode = "gt"
+	AtLeast  Mode = "gte"
+	Less     Mode = "lt"
+	AtMost   Mode = "lte"
+	Between  Mode = "between"
+)
+
+// Modes lists the match modes in the order they are offered to users.
+var Modes []Mode = []Mode{Exact, IExact, Prefix, Contains, Regex, Text, Greater, AtLeast, Less, AtMost, Between}
+
+// RangeSeparator splits the two ends of a between value, as in 1..10.
+const RangeSeparator = ".."
+
+// Criteria is a query together with how its value should be matched.
+// IExact, Prefix, Contains and Regex compare text, so they only match string
+// values. Text runs a full text query on text fields. The range modes work on
+// number and time fields; Between matches values from Query.Value to Upper,
+// both inclusive. Timestamps are compared as instants, Exact included.
+type Criteria struct {
+	Query types.Query
+	Mode  Mode
+	Upper interface{}
+}
+
+// IsTextMode reports whether the mode compares values as text, which only
+// string and text fields hold.
+func IsTextMode(mode Mode) bool {
+	switch mode {
+	case IExact, Prefix, Contains, Regex:
+		return true
+	}
+	return false
+}
+
+func IsRangeMode(mode Mode) bool {
+	switch mode {
+	case Greater, AtLeast, Less, AtMost, Between:
+		return true
+	}
+	return false
+}
+
+func ParseMode(name string) (Mode, error) {
+	for _, mode := range Modes {
+		if string(mode) == name {
+			return mode, nil
+		}
 	}
+	return "", fmt.Errorf("Unknown match mode %q, expected one of %v", name, Modes)
+}
+
+// SplitRange splits a between value such as 2016-01-01..2016-06-30 into its
+// lower and upper ends.
+func SplitRange(rawValue string) (string, string, error) {
+	parts := strings.Split(rawValue, RangeSeparator)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("Invalid range %q, expected <from>%s<to>", rawValue, RangeSeparator)
+	}
+	return parts[0], parts[1], nil
+}
 
-	resultSum = resultSum + fmt.Sprintln("Number of results ", len(results))
+// NewCriteria builds the criteria for a value typed by a user. Exact and
+// range values are coerced into the field's type, so errors say what the
+// field expects, and between values are split with SplitRange.
+func NewCriteria(dataset string, fieldName string, mode Mode, rawValue string) (Criteria, error) {
+	schema, ok := types.Schemas[dataset]
+	if !ok {
+		return Criteria{}, fmt.Errorf("Unknown dataset %q", dataset)
+	}
+
+	field, ok := schema.Field(fieldName)
+	if !ok {
+		return Criteria{}, fmt.Errorf("Unknown field %q for %s", fieldName, dataset)
+	}
+
+	criteria := Criteria{
+		Query: types.Query{Dataset: dataset, Field: fieldName, Value: rawValue},
+		Mode:  mode,
+	}
+
+	if IsTextMode(mode) && !index.IsStringField(dataset, fieldName) {
+		return Criteria{}, fmt.Errorf("%s %s doesn't support %s matching", dataset, fieldName, mode)
+	}
+
+	switch {
+	case mode == Exact:
+		value, err := field.Coerce(rawValue)
+		if err != nil {
+			return Criteria{}, err
+		}
+		criteria.Query.Value = value
+	case mode == Regex:
+		if _, err := regexp.Compile(rawValue); err != nil {
+			return Criteria{}, fmt.Errorf("Invalid regex: %w", err)
+		}
+	case IsRangeMode(mode):
+		if !index.IsRangeField(dataset, fieldName) {
+			return Criteria{}, fmt.Errorf("%s %s doesn't support range matching", dataset, fieldName)
+		}
+
+		lower, upper := rawValue, ""
+		if mode == Between {
+			var err error
+			if lower, upper, err = SplitRange(rawValue); err != nil {
+				return Criteria{}, err
+			}
+		}
+
+		value, err := field.Coerce(lower)
+		if err != nil {
+			return Criteria{}, err
+		}
+		criteria.Query.Value = value
+
+		if mode == Between {
+			if criteria.Upper, err = field.Coerce(upper); err != nil {
+				return Criteria{}, err
+			}
+		}
+
+		if isSpecial(criteria.Query.Value) || isSpecial(criteria.Upper) {
+			return Criteria{}, fmt.Errorf("%s and %s only work with %s matching", types.Missing, types.Empty, Exact)
+		}
+	}
+
+	return criteria, nil
+}
+
+func isSpecial(value interface{}) bool {
+	_, ok := value.(types.Special)
+	return ok
+}
+
+func Find(store *index.Store, criteria Criteria) ([]types.Record, error) {
+	query := criteria.Query
+
+	if criteria.Mode == "" || criteria.Mode == Exact {
+		// Timestamps typed in another zone than the data's are the same
+		// instant written differently, as a between match would find
+		if _, ok := query.Value.(string); ok && types.FieldTypeOf(query.Dataset, query.Field) == types.TimeField {
+			return findRange(store, Criteria{Query: query, Mode: Between, Upper: query.Value})
+		}
+		return store.Lookup(query), nil
+	}
+
+	if IsRangeMode(criteria.Mode) {
+		return findRange(store, criteria)
+	}
+
+	text, ok := query.Value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s matching needs a text value, got %v", criteria.Mode, query.Value)
+	}
+
+	if criteria.Mode == Text {
+		return store.SearchText(query.Dataset, query.Field, text)
+	}
+
+	var matches []string
+	switch criteria.Mode {
+	case IExact:
+		matches = store.EqualFold(query.Dataset, query.Field, text)
+	case Prefix:
+		matches = store.WithPrefix(query.Dataset, query.Field, text)
+	case Contains:
+		matches = store.Containing(query.Dataset, query.Field, text)
+	case Regex:
+		re, err := regexp.Compile(text)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regex: %w", err)
+		}
+		matches = store.Matching(query.Dataset, query.Field, re)
+	default:
+		return nil, fmt.Errorf("Unknown match mode %q", criteria.Mode)
+	}
+
+	values := make([]interface{}, len(matches))
+	for i, match := range matches {
+		values[i] = match
+	}
+
+	return lookupAll(store, query.Dataset, query.Field, values), nil
+}
+
+func findRange(store *index.Store, criteria Criteria) ([]types.Record, error) {
+	query := criteria.Query
+
+	if !index.IsRangeField(query.Dataset, query.Field) {
+		return nil, fmt.Errorf("%s %s doesn't support range matching", query.Dataset, query.Field)
+	}
+
+	key, err := index.RangeKey(query.Dataset, query.Field, query.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	var lower, upper *index.Bound
+	switch criteria.Mode {
+	case Greater:
+		lower = &index.Bound{Key: key}
+	case AtLeast:
+		lower = &index.Bound{Key: key, Inclusive: true}
+	case Less:
+		upper = &index.Bound{Key: key}
+	case AtMost:
+		upper = &index.Bound{Key: key, Inclusive: true}
+	case Between:
+		upperKey, err := index.RangeKey(query.Dataset, query.Field, criteria.Upper)
+		if err != nil {
+			return nil, err
+		}
+		lower = &index.Bound{Key: key, Inclusive: true}
+		upper = &index.Bound{Key: upperKey, Inclusive: true}
+	}
+
+	values := store.Range(query.Dataset, query.Field, lower, upper)
+
+	return lookupAll(store, query.Dataset, query.Field, values), nil
+}
+
+// lookupAll returns the records indexed under any of the values, each record
+// once even if several of its values matched.
+func lookupAll(lookup types.Lookup, dataset string, field string, values []interface{}) []types.Record {
+	var results []types.Record
+	seen := map[types.Query]bool{}
+
+	for _, value := range values {
+		for _, record := range lookup.Lookup(types.Query{Dataset: dataset, Field: field, Value: value}) {
+			key := record.PrimaryKey()
+			if !seen[key] {
+				seen[key] = true
+				results = append(results, record)
+			}
+		}
+	}
 
-	return resultSum
+	return results
 }
@@ -0,0 +1,204 @@
+// Package server serves searches over HTTP as JSON.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/index"
+	"github.com/zendesk/melbourne_code_club_go/internal/output"
+	"github.com/zendesk/melbourne_code_club_go/internal/search"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+// Server answers these requests from the store:
+//
+//	GET /search?dataset=&field=&value=[&match=][&depth=]  the matching records
+//	GET /fields/{dataset}                                 the searchable fields
+//	GET /{dataset}/{id}[?depth=]                          one record
+//
+// Records are encoded like the json output format, with their related
+// records nested depth relationships deep. Errors are returned as
+// {"error": "..."}.
+type Server struct {
+	source Source
+	mux    *http.ServeMux
+}
+
+// Source provides the store a request is answered from, such as an
+// index.Live that reloads the data files.
+type Source interface {
+	Store() *index.Store
+}
+
+func New(source Source) *Server {
+	s := &Server{source: source, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/search", s.handleSearch)
+	s.mux.HandleFunc("/fields/", s.handleFields)
+	s.mux.HandleFunc("/", s.handleRecord)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "Only GET is supported")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	mode := search.Exact
+	if match := params.Get("match"); match != "" {
+		var err error
+		if mode, err = search.ParseMode(match); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	depth, err := parseDepth(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, ok := params["value"]; !ok {
+		writeError(w, http.StatusBadRequest, "Missing value parameter")
+		return
+	}
+
+	criteria, err := search.NewCriteria(params.Get("dataset"), params.Get("field"), mode, params.Get("value"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	store := s.source.Store()
+
+	found, err := search.Find(store, criteria)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var body bytes.Buffer
+	if err := (output.JSON{Depth: depth}).Render(&body, store, found); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeBody(w, http.StatusOK, body.Bytes())
+}
+
+// field is how /fields describes a searchable field.
+type field struct {
+	Name  string          `json:"name"`
+	Type  types.FieldType `json:"type"`
+	Multi bool            `json:"multi"`
+}
+
+func (s *Server) handleFields(w http.ResponseWriter, r *http.Request) {
+	dataset := strings.TrimPrefix(r.URL.Path, "/fields/")
+	schema, ok := types.Schemas[dataset]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Unknown dataset "+dataset)
+		return
+	}
+
+	fields := []field{}
+	for _, f := range schema.Fields {
+		if f.Searchable {
+			fields = append(fields, field{Name: f.Name, Type: f.Type, Multi: f.Multi})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, fields)
+}
+
+func (s *Server) handleRecord(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	dataset, id := parts[0], parts[1]
+	schema, ok := types.Schemas[dataset]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Unknown dataset "+dataset)
+		return
+	}
+
+	depth, err := parseDepth(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	primaryKey, _ := schema.Field("_id")
+	value, err := primaryKey.Coerce(id)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	store := s.source.Store()
+
+	records := store.Lookup(types.Query{Dataset: dataset, Field: "_id", Value: value})
+	if len(records) == 0 {
+		writeError(w, http.StatusNotFound, "No "+dataset+" with _id "+id)
+		return
+	}
+
+	object, err := output.JSONObject(store, records[0], depth)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var body bytes.Buffer
+	if err := json.Indent(&body, object, "", "  "); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	body.WriteByte('\n')
+	writeBody(w, http.StatusOK, body.Bytes())
+}
+
+func parseDepth(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("depth")
+	if raw == "" {
+		return output.DefaultDepth, nil
+	}
+
+	depth, err := strconv.Atoi(raw)
+	if err != nil || depth < 0 {
+		return 0, fmt.Errorf("Invalid depth %q, expected 0 or more", raw)
+	}
+	return depth, nil
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	body, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeBody(w, status, append(body, '\n'))
+}
+
+func writeBody(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
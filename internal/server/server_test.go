@@ -0,0 +1,127 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/index"
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+type fixedSource struct {
+	store *index.Store
+}
+
+func (f fixedSource) Store() *index.Store {
+	return f.store
+}
+
+func newTestServer() *httptest.Server {
+	organization := 101
+	store := index.NewStore(map[string][]types.Record{
+		"users": {
+			types.User{Id: 1, Name: "Francisca Rasmussen", OrganizationId: &organization, Tags: []string{"Springville"}, Role: "admin"},
+			types.User{Id: 2, Name: "Cross Barlow", Tags: []string{"Foxworth"}, Role: "admin"},
+		},
+		"organizations": {
+			types.Organization{Id: 101, Name: "Enthaze", Details: "MegaCorp"},
+		},
+	})
+	return httptest.NewServer(New(fixedSource{store: store}))
+}
+
+func TestServer(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	for _, c := range []struct {
+		method string
+		path   string
+		status int
+		// body is a piece of the response expected in it
+		body string
+	}{
+		{"GET", "/search?dataset=users&field=role&value=admin", http.StatusOK, `"Cross Barlow"`},
+		{"GET", "/search?dataset=users&field=_id&value=1&depth=1", http.StatusOK, `"Enthaze"`},
+		{"GET", "/search?dataset=users&field=name&value=Nobody", http.StatusOK, `[]`},
+		{"GET", "/search?dataset=users&field=name&value=Fran&match=prefix", http.StatusOK, `"Francisca Rasmussen"`},
+		{"GET", "/search?dataset=users&field=_id", http.StatusBadRequest, `Missing value parameter`},
+		{"GET", "/search?dataset=nope&field=_id&value=1", http.StatusBadRequest, `Unknown dataset`},
+		{"GET", "/search?dataset=users&field=nope&value=1", http.StatusBadRequest, `Unknown field`},
+		{"GET", "/search?dataset=users&field=_id&value=one", http.StatusBadRequest, `"error"`},
+		{"GET", "/search?dataset=users&field=name&value=x&match=nope", http.StatusBadRequest, `"error"`},
+		{"GET", "/search?dataset=users&field=_id&value=1&match=prefix", http.StatusBadRequest, `doesn't support prefix matching`},
+		{"GET", "/search?dataset=users&field=name&value=x&depth=-1", http.StatusBadRequest, `Invalid depth`},
+		{"GET", "/fields/users", http.StatusOK, `"name": "organization_id"`},
+		{"GET", "/fields/nope", http.StatusNotFound, `Unknown dataset nope`},
+		{"GET", "/users/1", http.StatusOK, `"Francisca Rasmussen"`},
+		{"GET", "/organizations/101?depth=1", http.StatusOK, `"Francisca Rasmussen"`},
+		{"GET", "/users/one", http.StatusBadRequest, `"error"`},
+		{"GET", "/users/1?depth=x", http.StatusBadRequest, `Invalid depth`},
+		{"GET", "/users/3", http.StatusNotFound, `No users with _id 3`},
+		{"GET", "/nope/1", http.StatusNotFound, `Unknown dataset nope`},
+		{"GET", "/users/1/more", http.StatusNotFound, `Not found`},
+		{"POST", "/search?dataset=users&field=_id&value=1", http.StatusMethodNotAllowed, `Only GET is supported`},
+		{"DELETE", "/users/1", http.StatusMethodNotAllowed, `Only GET is supported`},
+	} {
+		request, err := http.NewRequest(c.method, server.URL+c.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var body json.RawMessage
+		err = json.NewDecoder(response.Body).Decode(&body)
+		response.Body.Close()
+		if err != nil {
+			t.Errorf("%s %s: response isn't JSON: %v", c.method, c.path, err)
+			continue
+		}
+
+		if response.StatusCode != c.status {
+			t.Errorf("%s %s: got status %d, expected %d: %s", c.method, c.path, response.StatusCode, c.status, body)
+		}
+		if !strings.Contains(string(body), c.body) {
+			t.Errorf("%s %s: got %s, expected it to hold %s", c.method, c.path, body, c.body)
+		}
+		if contentType := response.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+			t.Errorf("%s %s: got Content-Type %q", c.method, c.path, contentType)
+		}
+		if c.status == http.StatusMethodNotAllowed && response.Header.Get("Allow") != "GET, HEAD" {
+			t.Errorf("%s %s: got Allow %q", c.method, c.path, response.Header.Get("Allow"))
+		}
+	}
+}
+
+func TestSearchReadsTheCurrentStore(t *testing.T) {
+	source := &fixedSource{store: index.NewStore(map[string][]types.Record{})}
+	server := httptest.NewServer(New(source))
+	defer server.Close()
+
+	get := func() []json.RawMessage {
+		response, err := http.Get(server.URL + "/search?dataset=users&field=_id&value=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		var found []json.RawMessage
+		if err := json.NewDecoder(response.Body).Decode(&found); err != nil {
+			t.Fatal(err)
+		}
+		return found
+	}
+
+	if found := get(); len(found) != 0 {
+		t.Fatalf("found %d users in an empty store", len(found))
+	}
+	source.store = index.NewStore(map[string][]types.Record{"users": {types.User{Id: 1}}})
+	if found := get(); len(found) != 1 {
+		t.Errorf("found %d users after the store changed, expected 1", len(found))
+	}
+}
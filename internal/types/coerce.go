@@ -0,0 +1,58 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Coerce converts a value typed by a user into the field's type, so it can
+// be compared with the indexed values. Multi fields take a single element.
+// Surrounding double quotes are optional for text. @missing and @empty, or
+// nothing at all, search for records without a value.
+func (f Field) Coerce(raw string) (interface{}, error) {
+	raw = strings.TrimSpace(raw)
+
+	switch Special(raw) {
+	case Missing:
+		return Missing, nil
+	case Empty:
+		return Empty, nil
+	}
+
+	if raw == "" || raw == `""` {
+		return Empty, nil
+	}
+
+	switch f.Type {
+	case IntField:
+		number, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a whole number, got %q", f.Name, raw)
+		}
+		return number, nil
+	case BoolField:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false, got %q", f.Name, raw)
+		}
+		return value, nil
+	case TimeField:
+		t, err := ParseTime(unquote(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		return t.Format(TimeLayout), nil
+	default:
+		return unquote(raw), nil
+	}
+}
+
+func unquote(raw string) string {
+	if len(raw) >= 2 && strings.HasPrefix(raw, `"`) && strings.HasSuffix(raw, `"`) {
+		if unquoted, err := strconv.Unquote(raw); err == nil {
+			return unquoted
+		}
+	}
+	return raw
+}
@@ -0,0 +1,277 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/util"
+)
+
+// Document is a data file held as raw JSON objects, so records can be
+// edited and the file written back with its key order and formatting.
+type Document struct {
+	Path    string
+	Schema  *Schema
+	Objects []Object
+}
+
+// Object is a JSON object's members in the order they appear in the file.
+type Object []Member
+
+type Member struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// ReadDocument reads the data file at path. Its records aren't checked
+// against the schema; edited objects should be, with Schema.Validate.
+func ReadDocument(path string, schema *Schema) (*Document, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, &LoadError{File: path, Record: -1, Err: err}
+	}
+
+	var raws []json.RawMessage
+	if err := json.Unmarshal(body, &raws); err != nil {
+		return nil, newLoadError(path, -1, 0, err)
+	}
+
+	document := &Document{Path: path, Schema: schema, Objects: make([]Object, len(raws))}
+	for i, raw := range raws {
+		if err := document.Objects[i].UnmarshalJSON(raw); err != nil {
+			return nil, &LoadError{File: path, Record: i, Err: err}
+		}
+	}
+
+	return document, nil
+}
+
+// Find returns the position of the object with the given _id, or -1.
+func (d *Document) Find(id interface{}) int {
+	field, _ := d.Schema.Field("_id")
+	want, err := encodeValue(id)
+	if err != nil {
+		return -1
+	}
+
+	for i, object := range d.Objects {
+		if value, ok := object.Get(field.Key); ok && bytes.Equal(compact(value), want) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Write replaces the file with the document, indented by two spaces like
+// the exports, keeping the file's permissions.
+func (d *Document) Write() error {
+	var buffer bytes.Buffer
+	buffer.WriteByte('[')
+	for i, object := range d.Objects {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		encoded, err := object.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		buffer.Write(encoded)
+	}
+	buffer.WriteByte(']')
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buffer.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	indented.WriteByte('\n')
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(d.Path); err == nil {
+		mode = info.Mode()
+	}
+
+	return util.WriteFileAtomically(d.Path, mode, func(w io.Writer) error {
+		_, err := w.Write(indented.Bytes())
+		return err
+	})
+}
+
+func (o Object) Get(key string) (json.RawMessage, bool) {
+	for _, member := range o {
+		if member.Key == key {
+			return member.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set replaces the value of the key, or adds the key at the end.
+func (o *Object) Set(key string, value json.RawMessage) {
+	for i, member := range *o {
+		if member.Key == key {
+			(*o)[i].Value = value
+			return
+		}
+	}
+	*o = append(*o, Member{Key: key, Value: value})
+}
+
+func (o *Object) Delete(key string) {
+	kept := (*o)[:0]
+	for _, member := range *o {
+		if member.Key != key {
+			kept = append(kept, member)
+		}
+	}
+	*o = kept
+}
+
+// SetField stores a value typed by a user in the field, read like
+// Field.Coerce reads search values. Multi fields take a comma separated
+// list. @missing removes the field and @empty, or nothing at all, leaves a
+// text or list field empty.
+func (o *Object) SetField(field Field, raw string) error {
+	var value interface{}
+
+	if field.Multi {
+		elements := []interface{}{}
+		if coerced, err := field.Coerce(raw); err != nil {
+			return err
+		} else if coerced == Missing {
+			o.Delete(field.Key)
+			return nil
+		} else if coerced != Empty {
+			for _, element := range strings.Split(raw, ",") {
+				coerced, err := field.Coerce(element)
+				if err != nil {
+					return err
+				}
+				if _, ok := coerced.(Special); ok {
+					return fmt.Errorf("%s can't hold an empty element", field.Name)
+				}
+				elements = append(elements, coerced)
+			}
+		}
+		value = elements
+	} else {
+		coerced, err := field.Coerce(raw)
+		if err != nil {
+			return err
+		}
+
+		switch coerced {
+		case Missing:
+			o.Delete(field.Key)
+			return nil
+		case Empty:
+			if field.Type != StringField && field.Type != TextField {
+				return fmt.Errorf("%s can't be empty, use %s to remove it", field.Name, Missing)
+			}
+			value = ""
+		default:
+			value = coerced
+		}
+	}
+
+	encoded, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	o.Set(field.Key, encoded)
+	return nil
+}
+
+func (o Object) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, member := range o {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		key, err := encodeValue(member.Key)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(compact(member.Value))
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// UnmarshalJSON reads the object's members keeping their order, which
+// decoding into a map would lose.
+func (o *Object) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected a JSON object, got %v", token)
+	}
+
+	*o = nil
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+		*o = append(*o, Member{Key: token.(string), Value: value})
+	}
+
+	_, err = decoder.Token()
+	return err
+}
+
+// DecodeRecord decodes a JSON object of the dataset into its record type.
+func DecodeRecord(dataset string, raw json.RawMessage) (Record, error) {
+	switch dataset {
+	case "users":
+		var user User
+		err := json.Unmarshal(raw, &user)
+		return user, err
+	case "organizations":
+		var organization Organization
+		err := json.Unmarshal(raw, &organization)
+		return organization, err
+	case "tickets":
+		var ticket Ticket
+		err := json.Unmarshal(raw, &ticket)
+		return ticket, err
+	default:
+		return nil, fmt.Errorf("Unknown dataset %q", dataset)
+	}
+}
+
+// encodeValue writes value as JSON, leaving characters like & as they are
+// in the exports rather than escaping them.
+func encodeValue(value interface{}) (json.RawMessage, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buffer.Bytes(), "\n"), nil
+}
+
+func compact(raw json.RawMessage) []byte {
+	var buffer bytes.Buffer
+	if err := json.Compact(&buffer, raw); err != nil {
+		return raw
+	}
+	return buffer.Bytes()
+}
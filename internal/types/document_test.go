@@ -0,0 +1,88 @@
+package types
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	for _, dataset := range Datasets {
+		body, err := ioutil.ReadFile(filepath.Join("..", "..", "data", dataset+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dir, err := ioutil.TempDir("", "mcc-document")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		path := filepath.Join(dir, dataset+".json")
+		if err := ioutil.WriteFile(path, body, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		document, err := ReadDocument(path, Schemas[dataset])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := document.Write(); err != nil {
+			t.Fatal(err)
+		}
+
+		written, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(written, body) {
+			t.Errorf("%s changed when written back unchanged", dataset)
+		}
+	}
+}
+
+func TestSetField(t *testing.T) {
+	var object Object
+	if err := object.UnmarshalJSON([]byte(`{"_id": 1, "name": "Ada", "alias": "A", "tags": ["x"], "verified": true}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		field string
+		raw   string
+	}{
+		{"name", "Ada Quartz"},
+		{"alias", "@missing"},
+		{"tags", "a,b"},
+		{"verified", "@missing"},
+		{"role", "agent"},
+		{"signature", "@empty"},
+	} {
+		field, _ := UserSchema.Field(c.field)
+		if err := object.SetField(field, c.raw); err != nil {
+			t.Fatalf("%s=%s: %v", c.field, c.raw, err)
+		}
+	}
+
+	encoded, err := object.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"_id":1,"name":"Ada Quartz","tags":["a","b"],"role":"agent","signature":""}`
+	if string(encoded) != want {
+		t.Errorf("got %s, expected %s", encoded, want)
+	}
+
+	// A value the field can't hold leaves it as it was
+	before := append(Object(nil), object...)
+	field, _ := UserSchema.Field("_id")
+	if err := object.SetField(field, "@empty"); err == nil {
+		t.Error("_id was left empty")
+	}
+	if !reflect.DeepEqual(object, before) {
+		t.Error("a rejected value changed the object")
+	}
+}
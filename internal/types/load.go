@@ -0,0 +1,215 @@
+package types
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// LoadError describes where in a data file loading failed.
+type LoadError struct {
+	File string
+	// Record is the position of the failing record in the file's top level
+	// array, or -1 when the failure isn't tied to a record.
+	Record int
+	Line   int
+	Field  string
+	Err    error
+}
+
+func (e *LoadError) Error() string {
+	msg := e.File
+	if e.Line > 0 {
+		msg = fmt.Sprintf("%s:%d", msg, e.Line)
+	}
+	if e.Record >= 0 {
+		msg = fmt.Sprintf("%s: record %d", msg, e.Record)
+	}
+	if e.Field != "" {
+		msg = fmt.Sprintf("%s: field %q", msg, e.Field)
+	}
+	return fmt.Sprintf("%s: %v", msg, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// Progress tells how far loading a dataset's file has got.
+type Progress struct {
+	Dataset string
+	// Records is the number of records decoded so far.
+	Records int
+	// Read is how many bytes of the file have been decoded, out of Size.
+	Read int64
+	Size int64
+	// Done is set once the whole file has been read.
+	Done bool
+	// Indexed is set on the last report of all, sent by the index once the
+	// dataset can be searched.
+	Indexed bool
+}
+
+// StreamRecords decodes the dataset's file at path one record at a time,
+// calling emit with each record as soon as it is decoded rather than once
+// the whole file has been read, so memory use doesn't grow with the file.
+// Records emitted before a failure stay emitted.
+//
+// progress, unless nil, is called when the file is opened and after every
+// record. Decoding stops with ctx.Err() once ctx is done.
+func StreamRecords(ctx context.Context, dataset string, path string, emit func(Record) error, progress func(Progress)) error {
+	schema, ok := Schemas[dataset]
+	if !ok {
+		return fmt.Errorf("Unknown dataset %q", dataset)
+	}
+
+	return decodeFile(ctx, path, schema, func(raw json.RawMessage) error {
+		record, err := DecodeRecord(dataset, raw)
+		if err != nil {
+			return err
+		}
+		return emit(record)
+	}, progress)
+}
+
+// decodeFile reads a JSON array from path, checks every element against the
+// schema and calls decodeRecord with the elements that match. Schema problems
+// are collected across the whole file and returned as a *SchemaError.
+//
+// The file is streamed through the decoder, so only the element being
+// decoded is held in memory. Line numbers are worked out from the file again
+// only when something needs reporting.
+func decodeFile(ctx context.Context, path string, schema *Schema, decodeRecord func(json.RawMessage) error, progress func(Progress)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return &LoadError{File: path, Record: -1, Err: err}
+	}
+	defer file.Close()
+
+	report := func(Progress) {}
+	if progress != nil {
+		info, err := file.Stat()
+		if err != nil {
+			return &LoadError{File: path, Record: -1, Err: err}
+		}
+		report = func(p Progress) {
+			p.Dataset, p.Size = schema.Dataset, info.Size()
+			progress(p)
+		}
+	}
+	report(Progress{})
+
+	decoder := json.NewDecoder(file)
+
+	token, err := decoder.Token()
+	if err != nil {
+		return newLoadError(path, -1, 0, err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return newLoadError(path, -1, 0, fmt.Errorf("expected a JSON array, got %v", token))
+	}
+
+	var issues []SchemaIssue
+	var issueOffsets []int64
+
+	record := 0
+	for ; decoder.More(); record++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return newLoadError(path, record, decoder.InputOffset(), err)
+		}
+		// The decoder has just read past the element, so it started len(raw)
+		// bytes back
+		start := decoder.InputOffset() - int64(len(raw))
+
+		if problems := schema.Validate(raw); len(problems) > 0 {
+			for _, problem := range problems {
+				issues = append(issues, SchemaIssue{Record: record, Problem: problem})
+				issueOffsets = append(issueOffsets, start)
+			}
+			continue
+		}
+
+		if err := decodeRecord(raw); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return newLoadError(path, record, start, err)
+		}
+		report(Progress{Records: record + 1, Read: decoder.InputOffset()})
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return newLoadError(path, -1, decoder.InputOffset(), err)
+	}
+	report(Progress{Records: record, Read: decoder.InputOffset(), Done: true})
+
+	if len(issues) > 0 {
+		lines := linesAt(path, issueOffsets)
+		for i := range issues {
+			issues[i].Line = lines[i]
+		}
+		return &SchemaError{File: path, Dataset: schema.Dataset, Issues: issues}
+	}
+
+	return nil
+}
+
+// linesAt returns the line of each offset into the file at path, reading it
+// once. The offsets must be in ascending order. Lines are left at 0 if the
+// file can't be read.
+func linesAt(path string, offsets []int64) []int {
+	lines := make([]int, len(offsets))
+
+	file, err := os.Open(path)
+	if err != nil {
+		return lines
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	line, position := 1, int64(0)
+	for i, offset := range offsets {
+		for ; position < offset; position++ {
+			c, err := reader.ReadByte()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return lines
+			}
+			if c == '\n' {
+				line++
+			}
+		}
+		lines[i] = line
+	}
+
+	return lines
+}
+
+func newLoadError(path string, record int, offset int64, err error) *LoadError {
+	loadErr := &LoadError{File: path, Record: record, Err: err}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		loadErr.Field = typeErr.Field
+		offset += typeErr.Offset
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	}
+
+	loadErr.Line = linesAt(path, []int64{offset})[0]
+
+	return loadErr
+}
@@ -1,17 +1,7 @@
 package types
 
-import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"os"
-	"text/template"
-)
-
 type Organization struct {
-	Id            float64  `json:"_id"`
+	Id            int      `json:"_id"`
 	Url           string   `json:"url"`
 	ExternalId    string   `json:"external_id"`
 	DomainNames   []string `json:"domain_names"`
@@ -22,76 +12,26 @@ type Organization struct {
 	Details       string   `json:"details"`
 }
 
-var OrganizationFields []string = []string{"_id", "url", "external_id", "domain_names", "name", "created_at", "shared_tickets", "tags", "details"}
-
-func (o Organization) KeysForIndex() []Query {
-	query := []Query{
-		{Dataset: "organizations", Field: "_id", Value: o.Id},
-		{Dataset: "organizations", Field: "url", Value: o.Url},
-		{Dataset: "organizations", Field: "external_id", Value: o.ExternalId},
-		{Dataset: "organizations", Field: "name", Value: o.Name},
-		{Dataset: "organizations", Field: "created_at", Value: o.CreatedAt},
-		{Dataset: "organizations", Field: "shared_tickets", Value: o.SharedTickets},
-		{Dataset: "organizations", Field: "details", Value: o.Details},
-	}
-
-	for _, tag := range o.Tags {
-		query = append(query, Query{Dataset: "organizations", Field: "tags", Value: tag})
-	}
-
-	for _, tag := range o.DomainNames {
-		query = append(query, Query{Dataset: "organizations", Field: "domain_names", Value: tag})
-	}
-
-	return query
-}
-
-func (o Organization) Print(index Index) string {
-	return fmt.Sprintf("## Organization.\n%s", o.PrintBasicInfo())
+var OrganizationSchema *Schema = NewSchema("organizations", Organization{}, []Field{
+	{Name: "_id", Key: "_id", Type: IntField, Unique: true, Searchable: true, Displayable: true},
+	{Name: "url", Key: "url", Type: StringField, Searchable: true, Displayable: true},
+	{Name: "external_id", Key: "external_id", Type: StringField, Unique: true, Searchable: true, Displayable: true},
+	{Name: "domain_names", Key: "domain_names", Type: StringField, Multi: true, Searchable: true, Displayable: true},
+	{Name: "name", Key: "name", Type: StringField, Searchable: true, Displayable: true},
+	{Name: "created_at", Key: "created_at", Type: TimeField, Searchable: true, Displayable: true},
+	{Name: "shared_tickets", Key: "shared_tickets", Type: BoolField, Searchable: true, Displayable: true},
+	{Name: "tags", Key: "tags", Type: StringField, Multi: true, Searchable: true, Displayable: true},
+	{Name: "details", Key: "details", Type: TextField, Searchable: true, Displayable: true},
+})
+
+func (o Organization) PrimaryKey() Query {
+	return Query{Dataset: "organizations", Field: "_id", Value: o.Id}
 }
 
-func (o Organization) PrintBasicInfo() string {
-
-	var buf bytes.Buffer
-
-	templateBody :=
-		`		      _id: {{.Id}}
-	      external_id: {{.ExternalId}}
-  	   domain_names: {{.DomainNames}}
-	             name: {{.Name}}
-	       created_at: {{.CreatedAt}}
-	   shared_tickets: {{.SharedTickets}}
-	             tags: {{.Tags}}
-	          details: {{.Details}}`
-
-	tmpl, err := template.New("test").Parse(templateBody)
-
-	if err != nil {
-		panic(err)
-	}
-
-	err = tmpl.Execute(&buf, o)
-	if err != nil {
-		panic(err)
-	}
-
-	return buf.String()
+func (o Organization) KeysForIndex() []Query {
+	return OrganizationSchema.Keys(o)
 }
 
-func LoadOrganizations(ctx context.Context) []Organization {
-	jsonFile, err := os.Open("data/organizations.json")
-	if err != nil {
-		panic(err)
-	}
-
-	byteValue, _ := ioutil.ReadAll(jsonFile)
-
-	var organizations []Organization
-
-	err = json.Unmarshal(byteValue, &organizations)
-	if err != nil {
-		panic(err)
-	}
-
-	return organizations
+func (o Organization) Related(lookup Lookup) []Relation {
+	return OrganizationSchema.Related(lookup, o)
 }
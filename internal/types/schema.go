@@ -0,0 +1,306 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type FieldType string
+
+const (
+	StringField FieldType = "string"
+	// TextField is free text, tokenized for full text search.
+	TextField FieldType = "text"
+	IntField  FieldType = "int"
+	BoolField FieldType = "bool"
+	// TimeField holds timestamps in TimeLayout.
+	TimeField FieldType = "time"
+)
+
+// Field describes one field of a dataset.
+type Field struct {
+	// Name is how users refer to the field in queries and output.
+	Name string
+	// Key is the field's key in the JSON data files.
+	Key  string
+	Type FieldType
+	// Multi fields hold a list of values, each indexed on its own.
+	Multi bool
+	// Optional fields may be left out of a record in the data files.
+	Optional bool
+	// Unique fields hold a different value in every record of the dataset.
+	Unique bool
+	// References names the dataset whose _id the field holds. Relation
+	// names the record it refers to, such as "submitter", and Inverse the
+	// records referring back to that record, such as "submitted_tickets".
+	References  string
+	Relation    string
+	Inverse     string
+	Searchable  bool
+	Displayable bool
+
+	// index locates the field in the record's struct.
+	index []int
+}
+
+// Schema describes the fields of a dataset once, for loading, indexing,
+// prompting and printing.
+type Schema struct {
+	Dataset string
+	Fields  []Field
+	byName  map[string]int
+	byKey   map[string]int
+}
+
+// NewSchema binds the fields to the struct fields of record with the
+// matching json tag. It panics if one is missing, as that is a programming
+// error in the schema definition.
+func NewSchema(dataset string, record interface{}, fields []Field) *Schema {
+	recordType := reflect.TypeOf(record)
+	jsonKeys := map[string][]int{}
+	for i := 0; i < recordType.NumField(); i++ {
+		key := strings.Split(recordType.Field(i).Tag.Get("json"), ",")[0]
+		jsonKeys[key] = recordType.Field(i).Index
+	}
+
+	schema := &Schema{Dataset: dataset, Fields: fields, byName: map[string]int{}, byKey: map[string]int{}}
+	for i := range schema.Fields {
+		field := &schema.Fields[i]
+
+		index, ok := jsonKeys[field.Key]
+		if !ok {
+			panic(fmt.Sprintf("%s schema: %s has no field tagged json:%q", dataset, recordType, field.Key))
+		}
+
+		field.index = index
+		schema.byName[field.Name] = i
+		schema.byKey[field.Key] = i
+	}
+
+	return schema
+}
+
+// Schemas holds the schema of every dataset.
+var Schemas map[string]*Schema = map[string]*Schema{
+	"users":         UserSchema,
+	"organizations": OrganizationSchema,
+	"tickets":       TicketSchema,
+}
+
+func (s *Schema) Field(name string) (Field, bool) {
+	i, ok := s.byName[name]
+	if !ok {
+		return Field{}, false
+	}
+	return s.Fields[i], true
+}
+
+// FieldTypeOf returns the type of the named field of the dataset, or "" if
+// there is no such field.
+func FieldTypeOf(dataset string, name string) FieldType {
+	schema, ok := Schemas[dataset]
+	if !ok {
+		return ""
+	}
+	field, _ := schema.Field(name)
+	return field.Type
+}
+
+// SearchableFields returns the names of the fields that can be queried.
+func (s *Schema) SearchableFields() []string {
+	var names []string
+	for _, field := range s.Fields {
+		if field.Searchable {
+			names = append(names, field.Name)
+		}
+	}
+	return names
+}
+
+// Value returns the field's value on the record, or nil when it is missing.
+// Multi fields return the whole list.
+func (s *Schema) Value(record Record, field Field) interface{} {
+	value := reflect.ValueOf(record).FieldByIndex(field.index)
+	if isMissing(value) {
+		return nil
+	}
+	return reflect.Indirect(value).Interface()
+}
+
+// Keys returns the index keys of the record: one per searchable field, or
+// one per element for multi fields. Fields without a value are indexed
+// under Missing or Empty instead.
+func (s *Schema) Keys(record Record) []Query {
+	recordValue := reflect.ValueOf(record)
+
+	var keys []Query
+	for _, field := range s.Fields {
+		if !field.Searchable {
+			continue
+		}
+
+		value := recordValue.FieldByIndex(field.index)
+		switch {
+		case isMissing(value):
+			keys = append(keys, Query{Dataset: s.Dataset, Field: field.Name, Value: Missing})
+		case isEmpty(reflect.Indirect(value)):
+			keys = append(keys, Query{Dataset: s.Dataset, Field: field.Name, Value: Empty})
+		case field.Multi:
+			for i := 0; i < value.Len(); i++ {
+				keys = append(keys, Query{Dataset: s.Dataset, Field: field.Name, Value: value.Index(i).Interface()})
+			}
+		default:
+			keys = append(keys, Query{Dataset: s.Dataset, Field: field.Name, Value: reflect.Indirect(value).Interface()})
+		}
+	}
+
+	return keys
+}
+
+// isMissing reports whether the key was absent from the data: optional
+// fields are pointers and lists are nil until decoded.
+func isMissing(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		return value.IsNil()
+	}
+	return false
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String, reflect.Slice:
+		return value.Len() == 0
+	}
+	return false
+}
+
+// EmptyFields returns a bit for each field, by position in the schema, that
+// holds an empty list or points to a zero value. Encodings such as gob
+// don't tell those apart from missing fields, so they are noted on the side
+// and put back with WithEmptyFields.
+func (s *Schema) EmptyFields(record Record) uint64 {
+	recordValue := reflect.ValueOf(record)
+
+	var mask uint64
+	for i, field := range s.Fields {
+		value := recordValue.FieldByIndex(field.index)
+		switch {
+		case isMissing(value):
+		case value.Kind() == reflect.Ptr && value.Elem().IsZero(),
+			value.Kind() == reflect.Slice && value.Len() == 0:
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
+
+// WithEmptyFields returns a copy of the record with the fields of the mask
+// set back to an empty list or a pointer to a zero value.
+func (s *Schema) WithEmptyFields(record Record, mask uint64) Record {
+	if mask == 0 {
+		return record
+	}
+
+	copied := reflect.New(reflect.TypeOf(record)).Elem()
+	copied.Set(reflect.ValueOf(record))
+	for i, field := range s.Fields {
+		if mask&(1<<uint(i)) == 0 {
+			continue
+		}
+		value := copied.FieldByIndex(field.index)
+		switch value.Kind() {
+		case reflect.Ptr:
+			value.Set(reflect.New(value.Type().Elem()))
+		case reflect.Slice:
+			value.Set(reflect.MakeSlice(value.Type(), 0, 0))
+		}
+	}
+	return copied.Interface().(Record)
+}
+
+// Relation holds the records related to a record in one role. Many
+// relations gather the records referring back to it, any number of them;
+// the others hold the single record it refers to.
+type Relation struct {
+	Name    string
+	Many    bool
+	Records []Record
+}
+
+// Related resolves the relationships declared by References fields through
+// the lookup: first the records this one refers to, in field order, then
+// those referring back to it. Relations without records are left out.
+func (s *Schema) Related(lookup Lookup, record Record) []Relation {
+	var relations []Relation
+
+	for _, field := range s.Fields {
+		if field.References == "" {
+			continue
+		}
+		value := s.Value(record, field)
+		if value == nil {
+			continue
+		}
+		if found := lookup.Lookup(Query{Dataset: field.References, Field: "_id", Value: value}); len(found) > 0 {
+			relations = append(relations, Relation{Name: field.Relation, Records: found[:1]})
+		}
+	}
+
+	id := record.PrimaryKey().Value
+	for _, dataset := range Datasets {
+		for _, field := range Schemas[dataset].Fields {
+			if field.References != s.Dataset || field.Inverse == "" {
+				continue
+			}
+			if found := lookup.Lookup(Query{Dataset: dataset, Field: field.Name, Value: id}); len(found) > 0 {
+				relations = append(relations, Relation{Name: field.Inverse, Many: true, Records: found})
+			}
+		}
+	}
+
+	return relations
+}
+
+// RelatedDatasets returns the dataset and those Related can reach from its
+// records in up to depth steps, in Datasets order. These are the datasets a
+// search on the dataset needs to show related records that deep.
+func RelatedDatasets(dataset string, depth int) []string {
+	reached := map[string]bool{dataset: true}
+	frontier := []string{dataset}
+
+	for step := 0; step < depth && len(frontier) > 0; step++ {
+		var next []string
+		reach := func(other string) {
+			if !reached[other] {
+				reached[other] = true
+				next = append(next, other)
+			}
+		}
+
+		for _, from := range frontier {
+			for _, field := range Schemas[from].Fields {
+				if field.References != "" {
+					reach(field.References)
+				}
+			}
+			for _, other := range Datasets {
+				for _, field := range Schemas[other].Fields {
+					if field.References == from && field.Inverse != "" {
+						reach(other)
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+
+	var datasets []string
+	for _, other := range Datasets {
+		if reached[other] {
+			datasets = append(datasets, other)
+		}
+	}
+	return datasets
+}
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+)
+
+// Records go through gob as the Record interface, as in a snapshot
+func init() {
+	gob.Register(User{})
+}
+
+func TestEmptyFieldsSurviveGob(t *testing.T) {
+	blank := ""
+	no := false
+	zero := 0
+	for _, record := range []Record{
+		User{Id: 1, Alias: &blank, Verified: &no, OrganizationId: &zero, Tags: []string{}},
+		User{Id: 2, Tags: []string{"a"}},
+		User{Id: 3},
+	} {
+		mask := UserSchema.EmptyFields(record)
+
+		var encoded bytes.Buffer
+		if err := gob.NewEncoder(&encoded).Encode(&record); err != nil {
+			t.Fatal(err)
+		}
+		var decoded Record
+		if err := gob.NewDecoder(&encoded).Decode(&decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		restored := UserSchema.WithEmptyFields(decoded, mask)
+		if !reflect.DeepEqual(restored, record) {
+			t.Errorf("got %#v back, expected %#v", restored, record)
+		}
+		if !reflect.DeepEqual(restored.KeysForIndex(), record.KeysForIndex()) {
+			t.Errorf("user %d is filed differently after the round trip", record.PrimaryKey().Value)
+		}
+	}
+}
+
+func TestWithEmptyFieldsLeavesTheRecordAlone(t *testing.T) {
+	record := User{Id: 1}
+	mask := UserSchema.EmptyFields(User{Id: 1, Tags: []string{}})
+
+	restored := UserSchema.WithEmptyFields(record, mask).(User)
+	if record.Tags != nil {
+		t.Error("WithEmptyFields changed the record it was given")
+	}
+	if restored.Tags == nil || len(restored.Tags) != 0 {
+		t.Errorf("tags are %#v, expected an empty list", restored.Tags)
+	}
+}
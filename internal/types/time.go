@@ -0,0 +1,30 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeLayout is the timestamp format used throughout the data files,
+// e.g. 2016-04-15T05:19:46 -10:00.
+const TimeLayout = "2006-01-02T15:04:05 -07:00"
+
+// queryTimeLayouts are also accepted when parsing timestamps typed by users.
+// Layouts without a zone are read as UTC.
+var queryTimeLayouts []string = []string{
+	TimeLayout,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// ParseTime reads a timestamp in the data file format or, for user input,
+// RFC 3339 or a plain date.
+func ParseTime(value string) (time.Time, error) {
+	for _, layout := range queryTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Invalid timestamp %q, expected e.g. 2016-04-15T05:19:46 -10:00 or 2016-04-15", value)
+}
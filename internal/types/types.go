@@ -1,9 +1,17 @@
 package types
 
-var DataTypes map[string][]string = map[string][]string{
-	"users":         UserFields,
-	"organizations": OrganizationFields,
-	"tickets":       TicketFields,
+// Datasets lists the dataset names in the order they are presented to users.
+var Datasets []string = []string{"users", "organizations", "tickets"}
+
+// DataTypes lists the searchable fields of each dataset.
+var DataTypes map[string][]string = dataTypes()
+
+func dataTypes() map[string][]string {
+	fields := map[string][]string{}
+	for dataset, schema := range Schemas {
+		fields[dataset] = schema.SearchableFields()
+	}
+	return fields
 }
 
 type Database struct {
@@ -12,6 +20,16 @@ type Database struct {
 	Organizations []Organization
 }
 
+// Special values stand in for a field's value in the index and in queries
+// when the record has no value for it: Missing when the key is absent from
+// the data (or null), Empty when it holds "" or an empty list.
+type Special string
+
+const (
+	Missing Special = "@missing"
+	Empty   Special = "@empty"
+)
+
 type Query struct {
 	Dataset string
 	Field   string
@@ -19,16 +37,22 @@ type Query struct {
 }
 
 type Record interface {
-	Print(Index) string
-	PrintBasicInfo() string
+	// PrimaryKey identifies the record within all datasets.
+	PrimaryKey() Query
 	KeysForIndex() []Query
+	// Related returns the records this one refers to and the records
+	// referring to it that could be found.
+	Related(Lookup) []Relation
+}
+
+// Lookup finds the records filed under a query: an Index, or a store that
+// guards its index against concurrent updates.
+type Lookup interface {
+	Lookup(Query) []Record
 }
 
 type Index map[Query][]Record
 
-func findOne(index Index, query Query) Record {
-	if index[query] != nil {
-		return index[query][0]
-	}
-	return nil
+func (i Index) Lookup(query Query) []Record {
+	return i[query]
 }
@@ -0,0 +1,134 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxReportedIssues caps how many problems a SchemaError lists.
+const maxReportedIssues = 20
+
+// SchemaIssue is a problem found when checking a record against its schema.
+type SchemaIssue struct {
+	Record  int
+	Line    int
+	Problem string
+}
+
+// SchemaError reports every record in a data file that doesn't match the
+// dataset's schema.
+type SchemaError struct {
+	File    string
+	Dataset string
+	Issues  []SchemaIssue
+}
+
+func (e *SchemaError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d problems with the %s schema", e.File, len(e.Issues), e.Dataset)
+
+	for i, issue := range e.Issues {
+		if i == maxReportedIssues {
+			fmt.Fprintf(&b, "\n  ... and %d more", len(e.Issues)-maxReportedIssues)
+			break
+		}
+		fmt.Fprintf(&b, "\n  %s:%d: record %d: %s", e.File, issue.Line, issue.Record, issue.Problem)
+	}
+
+	return b.String()
+}
+
+// Validate checks a raw JSON record against the schema, describing unknown
+// keys, missing keys that aren't optional and values of the wrong type.
+// Null values count as missing.
+func (s *Schema) Validate(raw json.RawMessage) []string {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return []string{fmt.Sprintf("expected a JSON object: %v", err)}
+	}
+
+	var problems []string
+
+	var keys []string
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if _, ok := s.byKey[key]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown key %q", key))
+		}
+	}
+
+	for _, field := range s.Fields {
+		value, ok := object[field.Key]
+		if !ok || string(value) == "null" {
+			if !field.Optional {
+				problems = append(problems, fmt.Sprintf("missing key %q", field.Key))
+			}
+			continue
+		}
+
+		if problem := field.checkType(value); problem != "" {
+			problems = append(problems, fmt.Sprintf("key %q: %s", field.Key, problem))
+		}
+	}
+
+	return problems
+}
+
+// checkType describes how the raw value doesn't fit the field, or returns "".
+func (f Field) checkType(raw json.RawMessage) string {
+	if !f.Multi {
+		return f.checkElement(raw)
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(raw, &elements); err != nil {
+		return fmt.Sprintf("expected a list, got %s", raw)
+	}
+
+	for i, element := range elements {
+		if problem := f.checkElement(element); problem != "" {
+			return fmt.Sprintf("element %d: %s", i, problem)
+		}
+	}
+	return ""
+}
+
+func (f Field) checkElement(raw json.RawMessage) string {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return err.Error()
+	}
+
+	switch f.Type {
+	case IntField:
+		if number, ok := value.(float64); !ok || number != math.Trunc(number) {
+			return fmt.Sprintf("expected a whole number, got %s", raw)
+		}
+	case BoolField:
+		if _, ok := value.(bool); !ok {
+			return fmt.Sprintf("expected true or false, got %s", raw)
+		}
+	case StringField, TextField:
+		if _, ok := value.(string); !ok {
+			return fmt.Sprintf("expected a string, got %s", raw)
+		}
+	case TimeField:
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Sprintf("expected a timestamp string, got %s", raw)
+		}
+		if _, err := time.Parse(TimeLayout, text); err != nil {
+			return fmt.Sprintf("expected a timestamp like %s, got %s", TimeLayout, raw)
+		}
+	}
+
+	return ""
+}
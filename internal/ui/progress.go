@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zendesk/melbourne_code_club_go/internal/types"
+)
+
+const progressWidth = 20
+
+// Progress keeps track of loading the data so it can be shown while the
+// user picks a query. Report can be given to the loader as its progress
+// callback.
+type Progress struct {
+	mu       sync.Mutex
+	datasets map[string]types.Progress
+	finished bool
+}
+
+// Report records how far a dataset has got. It is safe to call from
+// several goroutines.
+func (p *Progress) Report(progress types.Progress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.datasets == nil {
+		p.datasets = map[string]types.Progress{}
+	}
+	p.datasets[progress.Dataset] = progress
+}
+
+// Finish marks the data as ready, or as failed to load, after which there
+// is nothing more to show.
+func (p *Progress) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.finished = true
+}
+
+// String describes the progress in one line, telling which datasets are
+// ready and how far the others have got, such as
+// "loading [#########-----------] 45% users ready, tickets 104", or returns
+// "" once loading has finished.
+func (p *Progress) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.finished {
+		return ""
+	}
+
+	var read, size int64
+	var states []string
+	for _, dataset := range types.Datasets {
+		progress, ok := p.datasets[dataset]
+		if !ok {
+			continue
+		}
+		read += progress.Read
+		size += progress.Size
+
+		switch {
+		case progress.Indexed:
+			states = append(states, dataset+" ready")
+		case progress.Done:
+			states = append(states, dataset+" indexing")
+		default:
+			states = append(states, fmt.Sprintf("%s %d", dataset, progress.Records))
+		}
+	}
+
+	// Until every file has been opened their total size isn't known
+	percent := int64(0)
+	if size > 0 && len(p.datasets) == len(types.Datasets) {
+		percent = read * 100 / size
+	}
+
+	filled := int(percent) * progressWidth / 100
+	return strings.TrimSpace(fmt.Sprintf("loading [%s%s] %d%% %s",
+		strings.Repeat("#", filled), strings.Repeat("-", progressWidth-filled),
+		percent, strings.Join(states, ", ")))
+}
+
+// Show redraws the progress on one line of w until done is closed, then
+// clears the line.
+func (p *Progress) Show(w io.Writer, done <-chan struct{}) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	width := 0
+	for {
+		line := p.String()
+		fmt.Fprintf(w, "\r%-*s", width, line)
+		if len(line) > width {
+			width = len(line)
+		}
+
+		select {
+		case <-done:
+			fmt.Fprintf(w, "\r%s\r", strings.Repeat(" ", width))
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// label is a prompt label followed by the loading progress, which is
+// rendered again whenever the prompt is redrawn.
+type label struct {
+	text     string
+	progress fmt.Stringer
+}
+
+func (l label) String() string {
+	if l.progress == nil {
+		return l.text
+	}
+	if status := l.progress.String(); status != "" {
+		return fmt.Sprintf("%s (%s)", l.text, status)
+	}
+	return l.text
+}
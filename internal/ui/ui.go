@@ -1,50 +1,126 @@
 package ui
 
 import (
-	"encoding/json"
+	"fmt"
 
 	"github.com/manifoldco/promptui"
+	"github.com/zendesk/melbourne_code_club_go/internal/expr"
+	"github.com/zendesk/melbourne_code_club_go/internal/index"
+	"github.com/zendesk/melbourne_code_club_go/internal/search"
 	"github.com/zendesk/melbourne_code_club_go/internal/types"
 	"github.com/zendesk/melbourne_code_club_go/internal/validation"
 )
 
-func PromptUser() (types.Query, error) {
+var modeLabels map[search.Mode]string = map[search.Mode]string{
+	search.Exact:    "exact value",
+	search.IExact:   "equals, ignoring case",
+	search.Prefix:   "starts with",
+	search.Contains: "contains",
+	search.Regex:    "matches regex",
+	search.Text:     "full text search, best matches first",
+	search.Greater:  "greater than / after",
+	search.AtLeast:  "at least / from",
+	search.Less:     "less than / before",
+	search.AtMost:   "at most / until",
+	search.Between:  "between <from>..<to>",
+}
+
+// expressionItem is offered next to the fields to write a query combining several of them.
+const expressionItem = "(combine fields with AND / OR / NOT)"
+
+// PromptUser asks for a query. progress, unless nil, is shown next to each
+// prompt while the data is still loading.
+func PromptUser(progress fmt.Stringer) (expr.Expr, error) {
 	datasetPrompt := promptui.Select{
-		Label: "Select Data Type",
-		Items: []string{"tickets", "organizations", "users"},
+		Label: label{"Select Data Type", progress},
+		Items: types.Datasets,
 	}
 
 	_, dataset, err := datasetPrompt.Run()
 
 	if err != nil {
-		return types.Query{}, err
+		return nil, err
 	}
 
 	acceptedFields := types.DataTypes[dataset]
 
 	fieldPrompt := promptui.Select{
-		Label: "Select Field",
-		Items: acceptedFields,
+		Label: label{"Select Field", progress},
+		Items: append([]string{expressionItem}, acceptedFields...),
 	}
 	_, field, err := fieldPrompt.Run()
 
 	if err != nil {
-		return types.Query{}, err
+		return nil, err
+	}
+
+	if field == expressionItem {
+		return promptExpression(dataset, progress)
+	}
+
+	var modes []search.Mode
+	var modeItems []string
+	for _, mode := range search.Modes {
+		if mode == search.Text && !index.IsFullText(dataset, field) {
+			continue
+		}
+		if search.IsRangeMode(mode) && !index.IsRangeField(dataset, field) {
+			continue
+		}
+		if search.IsTextMode(mode) && !index.IsStringField(dataset, field) {
+			continue
+		}
+		modes = append(modes, mode)
+		modeItems = append(modeItems, modeLabels[mode])
+	}
+
+	modePrompt := promptui.Select{
+		Label: label{"How should it match?", progress},
+		Items: modeItems,
+	}
+	modeIndex, _, err := modePrompt.Run()
+
+	if err != nil {
+		return nil, err
 	}
 
+	mode := modes[modeIndex]
+
 	inputValuePrompt := promptui.Prompt{
-		Label:    "What are you searching for, dear User?",
-		Validate: validation.SearchQuery,
+		Label:    label{"What are you searching for, dear User?", progress},
+		Validate: validation.SearchValue(dataset, field, mode),
 	}
 
 	inputValue, err := inputValuePrompt.Run()
 
 	if err != nil {
-		return types.Query{}, err
+		return nil, err
+	}
+
+	criteria, err := search.NewCriteria(dataset, field, mode, inputValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return expr.Term{Criteria: criteria}, nil
+}
+
+func promptExpression(dataset string, progress fmt.Stringer) (expr.Expr, error) {
+	fmt.Println(expr.Syntax)
+
+	expressionPrompt := promptui.Prompt{
+		Label: label{"Query", progress},
+		Validate: func(text string) error {
+			_, err := expr.Parse(dataset, text)
+			return err
+		},
 	}
 
-	var value interface{}
-	json.Unmarshal([]byte(inputValue), &value)
+	text, err := expressionPrompt.Run()
+
+	if err != nil {
+		return nil, err
+	}
 
-	return types.Query{Dataset: dataset, Field: field, Value: value}, nil
+	return expr.Parse(dataset, text)
 }
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomically replaces the file at path with what write writes. It
+// is written to a temporary file next to it, synced and renamed into place,
+// so readers never see a partly written file and a failure leaves the old
+// one as it was.
+func WriteFileAtomically(path string, mode os.FileMode, write func(io.Writer) error) error {
+	temp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	// Does nothing once the rename has happened
+	defer os.Remove(temp.Name())
+
+	writer := bufio.NewWriter(temp)
+	if err := write(writer); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), mode); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
@@ -1,13 +1,14 @@
 package validation
 
 import (
-	"encoding/json"
-	"fmt"
+	"github.com/zendesk/melbourne_code_club_go/internal/search"
 )
 
-func SearchQuery(searchQuery string) error {
-	if !json.Valid([]byte(searchQuery)) {
-		return fmt.Errorf("Invalid search query, must be json")
+// SearchValue returns a prompt validator checking the value suits the field's
+// type and the match mode.
+func SearchValue(dataset string, field string, mode search.Mode) func(string) error {
+	return func(searchValue string) error {
+		_, err := search.NewCriteria(dataset, field, mode, searchValue)
+		return err
 	}
-	return nil
 }